@@ -0,0 +1,184 @@
+package go_cache
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GroupInfo describes a group in admin responses
+type GroupInfo struct {
+	Name       string   `json:"name"`
+	CacheBytes int64    `json:"cache_bytes"` // byte budget, 0 means no limit
+	TTL        string   `json:"ttl"`         // 0s means never expire
+	Bytes      int64    `json:"bytes"`       // bytes used by cached keys and values
+	Items      int      `json:"items"`
+	DiskBytes  int64    `json:"disk_bytes,omitempty"` // bytes of values spilled to disk
+	DiskItems  int      `json:"disk_items,omitempty"`
+	HotBytes   int64    `json:"hot_bytes,omitempty"` // bytes of replicated hot keys
+	HotItems   int      `json:"hot_items,omitempty"`
+	HotKeys    []HotKey `json:"hot_keys,omitempty"` // most frequently got keys
+	Stats      Stats    `json:"stats"`
+}
+
+// PoolInfo describes an HTTPPool in admin responses
+type PoolInfo struct {
+	Self  string   `json:"self"`
+	Peers []string `json:"peers"`
+}
+
+// Info describes the configuration and usage of the group
+func (g *Group) Info() GroupInfo {
+	items, bytes, cacheBytes := g.mainCache.stats()
+	disk := g.diskStats()
+	hotItems, hotBytes, _ := g.hotCache.stats()
+	return GroupInfo{
+		Name:       g.name,
+		CacheBytes: cacheBytes,
+		TTL:        g.TTL().String(),
+		Bytes:      bytes,
+		Items:      items,
+		DiskBytes:  disk.Bytes,
+		DiskItems:  disk.Items,
+		HotBytes:   hotBytes,
+		HotItems:   hotItems,
+		HotKeys:    g.HotKeys(infoHotKeys),
+		Stats:      g.Stats(),
+	}
+}
+
+// serveAdmin serves the admin endpoints under "<basePath>_admin/"
+//
+//	GET    peers                  list peers of the pool
+//	GET    groups                 list groups
+//	GET    groups/<name>          describe a group
+//	PUT    groups/<name>?cache_bytes=1024&ttl=1m   reconfigure a group
+//	DELETE groups/<name>          delete a group
+//	GET    groups/<name>/keys     scan keys, see serveKeyScan
+//	DELETE groups/<name>/keys     delete keys by pattern
+//
+// Requests other than GET need the token set by SetAdminToken, if any.
+func (p *HTTPPool) serveAdmin(w http.ResponseWriter, r *http.Request, path string) {
+	if !p.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="go_cache admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	resource, name, _ := strings.Cut(path, "/")
+	if resource == "peers" {
+		writeJSON(w, PoolInfo{Self: p.poolName, Peers: p.Peers()})
+		return
+	}
+	if resource != "groups" {
+		http.Error(w, "no such resource: "+resource, http.StatusNotFound)
+		return
+	}
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		infos := make([]GroupInfo, 0)
+		for _, g := range p.registry.all() {
+			infos = append(infos, g.Info())
+		}
+		writeJSON(w, infos)
+		return
+	}
+
+	name, sub, _ := strings.Cut(name, "/")
+	g := p.registry.GetGroup(name)
+	if g == nil {
+		http.Error(w, "no such group: "+name, http.StatusNotFound)
+		return
+	}
+	if sub == "keys" {
+		p.serveKeyScan(w, r, g)
+		return
+	} else if sub != "" {
+		http.Error(w, "no such resource: "+sub, http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if err := reconfigure(g, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Log("reconfigure group %s", name)
+	case http.MethodDelete:
+		p.registry.DeleteGroup(name)
+		p.Log("delete group %s", name)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, g.Info())
+}
+
+// SetAdminToken requires "Authorization: Bearer <token>" on the admin requests
+// that reconfigure or delete groups or delete keys, which are open to anyone
+// reaching the pool otherwise, and on the invalidations broadcast by peers.
+// Every node of a cluster needs the same token, the deletions of keys and
+// the invalidations being sent to the peers. It must be called before
+// serving.
+func (p *HTTPPool) SetAdminToken(token string) {
+	p.adminToken = token
+}
+
+// authorized reports whether r may change the groups of the node
+func (p *HTTPPool) authorized(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || p.hasAdminToken(r)
+}
+
+// hasAdminToken reports whether r carries the admin token, if one is set
+func (p *HTTPPool) hasAdminToken(r *http.Request) bool {
+	if p.adminToken == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(p.adminToken)) == 1
+}
+
+// reconfigure applies the "cache_bytes" and "ttl" query parameters, all or nothing
+func reconfigure(g *Group, r *http.Request) error {
+	query := r.URL.Query()
+
+	var cacheBytes int64 = -1
+	var ttl time.Duration = -1
+	var err error
+	if v := query.Get("cache_bytes"); v != "" {
+		if cacheBytes, err = strconv.ParseInt(v, 10, 64); err != nil || cacheBytes < 0 {
+			return fmt.Errorf("bad cache_bytes %q", v)
+		}
+	}
+	if v := query.Get("ttl"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil || ttl < 0 {
+			return fmt.Errorf("bad ttl %q", v)
+		}
+	}
+
+	if cacheBytes >= 0 {
+		g.SetCacheBytes(cacheBytes)
+	}
+	if ttl >= 0 {
+		g.SetTTL(ttl)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
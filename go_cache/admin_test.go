@@ -0,0 +1,150 @@
+package go_cache_test
+
+import (
+	"encoding/json"
+	"errors"
+	"go_cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGroupLifecycle(t *testing.T) {
+	getter := go_cache.GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	g, err := go_cache.CreateGroup("lifecycle", 0, getter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		err  bool
+	}{
+		{"lifecycle", true},
+		{"", true},
+		{"_internal", true},
+		{"a/b", true},
+	}
+	for _, tt := range tests {
+		if _, err := go_cache.CreateGroup(tt.name, 0, getter); (err != nil) != tt.err {
+			t.Errorf("CreateGroup(%q) err = %v", tt.name, err)
+		}
+	}
+	if _, err := go_cache.CreateGroup("lifecycle", 0, getter); !errors.Is(err, go_cache.ErrGroupExists) {
+		t.Fatalf("duplicate CreateGroup err = %v, want ErrGroupExists", err)
+	}
+
+	// NewGroup replaces a group of the same name, as it always has
+	r := go_cache.NewRegistry()
+	r.NewGroup("replaced", 0, getter)
+	if g2 := r.NewGroup("replaced", 0, getter); r.GetGroup("replaced") != g2 {
+		t.Fatal("NewGroup did not replace the group of the same name")
+	}
+
+	g.Get("k1")
+	g.Get("k2")
+	g.SetCacheBytes(4)
+	if info := g.Info(); info.Items != 1 || info.Bytes != 4 || info.CacheBytes != 4 {
+		t.Fatalf("after shrinking Info() = %+v", info)
+	}
+
+	if !go_cache.DeleteGroup("lifecycle") || go_cache.DeleteGroup("lifecycle") {
+		t.Fatal("DeleteGroup should succeed once")
+	}
+	if go_cache.GetGroup("lifecycle") != nil {
+		t.Fatal("deleted group still registered")
+	}
+	for _, name := range go_cache.ListGroups() {
+		if name == "lifecycle" {
+			t.Fatal("deleted group still listed")
+		}
+	}
+	if _, err := g.Get("k1"); err != go_cache.ErrGroupDeleted {
+		t.Fatalf("Get on deleted group err = %v", err)
+	}
+	if info := g.Info(); info.Items != 0 || info.Bytes != 0 {
+		t.Fatalf("deleted group still holds values: %+v", info)
+	}
+}
+
+func TestAdminGroups(t *testing.T) {
+	go_cache.NewGroup("admin", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	pool := go_cache.NewHTTPPool("admin")
+	pool.SetAdminToken("s3cret")
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+
+	doAs := func(token, method, path string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+"/_go_cache/_admin/groups"+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	do := func(method, path string) *http.Response {
+		return doAs("s3cret", method, path)
+	}
+
+	// only reads are open without the token
+	for _, tt := range []struct{ token, method, path string }{
+		{"", http.MethodPut, "/admin?ttl=1m"},
+		{"", http.MethodDelete, "/admin"},
+		{"wrong", http.MethodDelete, "/admin/keys?pattern=*"},
+	} {
+		res := doAs(tt.token, tt.method, tt.path)
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("%s %s with token %q: status %s, want 401", tt.method, tt.path, tt.token, res.Status)
+		}
+	}
+	res := doAs("", http.MethodGet, "/admin")
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET without token: status %s", res.Status)
+	}
+
+	res = do(http.MethodPut, "/admin?cache_bytes=2048&ttl=1m")
+	var info go_cache.GroupInfo
+	json.NewDecoder(res.Body).Decode(&info)
+	res.Body.Close()
+	if info.CacheBytes != 2048 || info.TTL != time.Minute.String() {
+		t.Fatalf("reconfigured group = %+v", info)
+	}
+
+	if res := do(http.MethodPut, "/admin?ttl=soon"); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bad ttl status %s", res.Status)
+	}
+
+	res = do(http.MethodGet, "")
+	var infos []go_cache.GroupInfo
+	json.NewDecoder(res.Body).Decode(&infos)
+	res.Body.Close()
+	found := false
+	for _, info := range infos {
+		found = found || info.Name == "admin"
+	}
+	if !found {
+		t.Fatalf("group admin not listed in %+v", infos)
+	}
+
+	if res := do(http.MethodDelete, "/admin"); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete status %s", res.Status)
+	}
+	res, err := http.Get(srv.URL + "/_go_cache/admin/k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("peer request to deleted group status %s", res.Status)
+	}
+}
@@ -1,9 +1,14 @@
 package go_cache
 
+import "time"
+
 // ByteView holds an immutable view of bytes.
 // It is encapsulation of lru
 type ByteView struct {
-	b []byte // caching arbitary format data
+	b       []byte    // caching arbitary format data
+	expire  time.Time // zero means never expire
+	version uint64    // assigned by the node owning the key, 0 means unversioned
+	flags   uint32    // opaque to the cache, set by writers such as memcached clients
 }
 
 func (v ByteView) Len() int {
@@ -11,11 +16,34 @@ func (v ByteView) Len() int {
 }
 
 func (v ByteView) ByteSlice() []byte {
-	c := make([]byte, len(v.b))
-	copy(c, v.b)
-	return c
-
+	return cloneBytes(v.b)
 }
 func (v ByteView) String() string {
 	return string(v.b)
 }
+
+// Expire returns the time after which the value is stale. Zero means never.
+func (v ByteView) Expire() time.Time {
+	return v.expire
+}
+
+// Version returns the version assigned by the node owning the key.
+// Versions of a key grow with every load or write on its owner.
+func (v ByteView) Version() uint64 {
+	return v.version
+}
+
+// Flags returns the opaque flags stored along with the value.
+func (v ByteView) Flags() uint32 {
+	return v.flags
+}
+
+func (v ByteView) expired(now time.Time) bool {
+	return !v.expire.IsZero() && now.After(v.expire)
+}
+
+func cloneBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -2,37 +2,331 @@ package go_cache
 
 import (
 	"go_cache/lru"
+	"go_cache/radix"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // cache is a concurrent accessible encapsulation of lru
 type cache struct {
 	mu         sync.Mutex
-	lru        *lru.Cache
+	lru        *lru.LRU[string, ByteView]
 	cacheBytes int64
+	closed     bool        // refuse values after the group is deleted
+	index      *radix.Tree // optional order of the keys, updated with lru
+
+	// optional and receives events after mu is released
+	onEvent func(key string, value ByteView, reason EventReason)
+	pending []cacheEvent
+	reason  EventReason // reason reported when lru purges an entry
+
+	evictions   atomic.Int64 // values purged to keep within cacheBytes
+	expirations atomic.Int64 // values dropped after their TTL
+}
+
+type cacheEvent struct {
+	key    string
+	value  ByteView
+	reason EventReason
+}
+
+func sizeOfEntry(key string, value ByteView) int64 {
+	return int64(len(key)) + int64(value.Len())
+}
+
+func (c *cache) lazyInit() {
+	if c.lru == nil {
+		c.lru = lru.NewLRU(c.cacheBytes, sizeOfEntry, c.purged)
+		c.reason = EventEvict
+	}
+}
+
+// purged is called by lru with mu held
+func (c *cache) purged(key string, value ByteView) {
+	switch c.reason {
+	case EventEvict:
+		c.evictions.Add(1)
+	case EventExpire:
+		c.expirations.Add(1)
+	}
+	c.record(key, value, c.reason)
+}
+
+func (c *cache) record(key string, value ByteView, reason EventReason) {
+	if c.index != nil {
+		if reason == EventInsert {
+			c.index.Insert(key)
+		} else {
+			c.index.Delete(key)
+		}
+	}
+	if c.onEvent != nil {
+		c.pending = append(c.pending, cacheEvent{key, value, reason})
+	}
+}
+
+// unlock releases mu and then delivers the events recorded while it was held,
+// so handlers are free to call back into the cache.
+func (c *cache) unlock() {
+	events := c.pending
+	c.pending = nil
+	onEvent := c.onEvent
+	c.mu.Unlock()
+
+	for _, e := range events {
+		onEvent(e.key, e.value, e.reason)
+	}
+}
+
+func (c *cache) setOnEvent(fn func(key string, value ByteView, reason EventReason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvent = fn
 }
 
 func (c *cache) add(key string, value ByteView) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.closed {
+		return
+	}
+	c.lazyInit()
+	c.record(key, value, EventInsert)
+	c.lru.Add(key, value)
+}
+
+// compareAndAdd adds value if the version of the cached one is version,
+// treating missing and expired values as version 0.
+func (c *cache) compareAndAdd(key string, value ByteView, version uint64) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.closed {
+		return false
+	}
+	c.lazyInit()
+	var current uint64
+	if v, ok := c.lru.Peek(key); ok && !v.expired(time.Now()) {
+		current = v.version
+	}
+	if current != version {
+		return false
+	}
+	c.record(key, value, EventInsert)
+	c.lru.Add(key, value)
+	return true
+}
+
+// fill adds value loaded while version was cached for key, 0 if nothing
+// was. A live value written meanwhile is kept and returned instead.
+func (c *cache) fill(key string, value ByteView, version uint64) ByteView {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.closed {
+		return value
+	}
+	c.lazyInit()
+	if v, ok := c.lru.Peek(key); ok && v.version != version && !v.expired(time.Now()) {
+		return v
+	}
+	c.record(key, value, EventInsert)
+	c.lru.Add(key, value)
+	return value
+}
+
+// version returns the version cached for key, expired or not, 0 if none
+func (c *cache) version(key string) uint64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
+		return 0
 	}
-	c.lru.Add(key, value)
+	v, _ := c.lru.Peek(key)
+	return v.version
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+// touch changes the expiry of a cached value
+func (c *cache) touch(key string, expire time.Time) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.lru == nil {
+		return false
+	}
+	v, ok := c.lru.Peek(key)
+	if !ok || v.expired(time.Now()) {
+		return false
+	}
+	v.expire = expire
+	c.lru.Add(key, v)
+	return true
+}
+
+// get returns the value of key. Expired values are dropped unless they
+// expired within grace, in which case the caller decides how to use them.
+func (c *cache) get(key string, grace time.Duration) (value ByteView, ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.lru == nil {
+		return
+	}
+
+	if value, ok = c.lru.Get(key); ok && value.expired(time.Now().Add(-grace)) {
+		c.removeLocked(key, EventExpire)
+		return ByteView{}, false
+	}
+	return
+}
+
+func (c *cache) remove(key string) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.lru == nil {
+		return false
+	}
+	return c.removeLocked(key, EventRemove)
+}
+
+func (c *cache) removeLocked(key string, reason EventReason) bool {
+	c.reason = reason
+	defer func() { c.reason = EventEvict }()
+	return c.lru.Remove(key)
+}
+
+func (c *cache) clear() {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.lru == nil {
+		return
+	}
+	c.reason = EventRemove
+	defer func() { c.reason = EventEvict }()
+	c.lru.Clear()
+}
+
+// keys returns up to limit keys from the most recently used, limit <= 0 means all
+func (c *cache) keys(limit int) []string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.lru == nil {
+		return nil
+	}
+	if limit <= 0 || limit > c.lru.Len() {
+		limit = c.lru.Len()
+	}
+	keys := make([]string, 0, limit)
+	c.lru.Range(func(key string, value ByteView) bool {
+		keys = append(keys, key)
+		return len(keys) < limit
+	})
+	return keys
+}
+
+// peek returns the value of key unless it expired, leaving the order of
+// eviction and the values kept for stale-if-error as they are
+func (c *cache) peek(key string) (ByteView, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru == nil {
+		return ByteView{}, false
+	}
+	v, ok := c.lru.Peek(key)
+	if !ok || v.expired(time.Now()) {
+		return ByteView{}, false
+	}
+	return v, true
+}
+
+// enableIndex keeps the keys in order for scan
+func (c *cache) enableIndex() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.index != nil {
 		return
 	}
+	c.index = new(radix.Tree)
+	if c.lru != nil {
+		c.lru.Range(func(key string, value ByteView) bool {
+			c.index.Insert(key)
+			return true
+		})
+	}
+}
+
+// scan calls fn in order with the unexpired keys starting with prefix that
+// sort after cursor, until fn returns false. fn must not call into c.
+// It reports false when the keys are not indexed.
+func (c *cache) scan(prefix string, cursor string, fn func(key string) bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+	if c.index == nil {
+		return false
 	}
+	if c.lru == nil {
+		return true
+	}
+	now := time.Now()
+	c.index.WalkPrefix(prefix, cursor, func(key string) bool {
+		if v, ok := c.lru.Peek(key); !ok || v.expired(now) {
+			return true
+		}
+		return fn(key)
+	})
+	return true
+}
 
-	return
+func (c *cache) contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru == nil {
+		return false
+	}
+	v, ok := c.lru.Peek(key)
+	return ok && !v.expired(time.Now())
+}
+
+// close drops all values and refuses new ones, releasing the memory
+func (c *cache) close() {
+	c.clear()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.lru = nil
+	if c.index != nil {
+		c.index = new(radix.Tree)
+	}
+}
+
+func (c *cache) setCacheBytes(cacheBytes int64) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.cacheBytes = cacheBytes
+	if c.lru != nil {
+		c.lru.SetMaxBytes(cacheBytes)
+	}
+}
+
+// stats returns the number of entries, their bytes and the byte budget
+func (c *cache) stats() (items int, bytes int64, cacheBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru == nil {
+		return 0, 0, c.cacheBytes
+	}
+	return c.lru.Len(), c.lru.Bytes(), c.cacheBytes
 }
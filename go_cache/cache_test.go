@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"go_cache"
 	"log/slog"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGet(t *testing.T) {
@@ -44,3 +47,153 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+func TestCompareAndSet(t *testing.T) {
+	g := go_cache.NewGroup("cas", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("loaded"), nil
+		}))
+
+	if _, err := g.CompareAndSet("k1", 1, []byte("v")); err != go_cache.ErrVersionMismatch {
+		t.Fatalf("CompareAndSet on missing key with version 1: err = %v", err)
+	}
+	first, err := g.CompareAndSet("k1", 0, []byte("v1"))
+	if err != nil || first.String() != "v1" || first.Version() == 0 {
+		t.Fatalf("CompareAndSet(k1, 0) = %v, %v", first, err)
+	}
+	if view, _ := g.Get("k1"); view.Version() != first.Version() || view.String() != "v1" {
+		t.Fatalf("Get(k1) = %s@%d, want v1@%d", view, view.Version(), first.Version())
+	}
+
+	tests := []struct {
+		expected uint64
+		value    string
+		err      error
+	}{
+		{0, "v2", go_cache.ErrVersionMismatch},
+		{first.Version() - 1, "v2", go_cache.ErrVersionMismatch},
+		{first.Version(), "v2", nil},
+		{first.Version(), "v3", go_cache.ErrVersionMismatch}, // lost the race to v2
+	}
+	last := first
+	for _, tt := range tests {
+		view, err := g.CompareAndSet("k1", tt.expected, []byte(tt.value))
+		if err != tt.err {
+			t.Fatalf("CompareAndSet(k1, %d, %s) err = %v, want %v", tt.expected, tt.value, err, tt.err)
+		}
+		if err == nil {
+			if view.Version() <= last.Version() {
+				t.Fatalf("version %d not greater than %d", view.Version(), last.Version())
+			}
+			last = view
+		}
+	}
+	if view, _ := g.Get("k1"); view.String() != "v2" {
+		t.Fatalf("Get(k1) = %s, want v2", view)
+	}
+}
+
+func TestLoadKeepsWrite(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	g := go_cache.NewGroup("load-keeps-write", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("loaded"), nil
+		}))
+
+	done := make(chan go_cache.ByteView)
+	go func() {
+		view, _ := g.Get("k1")
+		done <- view
+	}()
+	<-started
+	written, err := g.Set("k1", []byte("written"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the load finishing last must not overwrite the write
+	close(release)
+	if view := <-done; view.String() != "written" || view.Version() != written.Version() {
+		t.Fatalf("Get(k1) during the write = %s@%d, want written@%d", view, view.Version(), written.Version())
+	}
+	if view, _ := g.Get("k1"); view.String() != "written" {
+		t.Fatalf("Get(k1) = %s, want written", view)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var loads atomic.Int32
+	reloading, release := make(chan struct{}), make(chan struct{})
+	g := go_cache.NewGroup("refresh-ahead", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			n := loads.Add(1)
+			if n == 2 {
+				close(reloading)
+				<-release // hold the background reload
+			}
+			return []byte(strconv.Itoa(int(n))), nil
+		}))
+	refreshed := make(chan struct{})
+	g.Subscribe(func(e go_cache.Event) {
+		if e.Reason == go_cache.EventInsert && e.Value.String() == "2" {
+			close(refreshed)
+		}
+	})
+	g.SetTTL(time.Hour)
+	g.SetRefreshAhead(time.Minute)
+
+	// outside the window: served from cache only
+	for i := 0; i < 2; i++ {
+		if view, _ := g.Get("k1"); view.String() != "1" {
+			t.Fatalf("Get(k1) = %s, want 1", view)
+		}
+	}
+	if got := loads.Load(); got != 1 {
+		t.Fatalf("loads = %d, want 1", got)
+	}
+
+	// within the window: served from cache while reloading in background
+	g.SetRefreshAhead(2 * time.Hour)
+	g.Get("k1")
+	<-reloading
+	for i := 0; i < 5; i++ {
+		if view, _ := g.Get("k1"); view.String() != "1" {
+			t.Fatalf("Get(k1) = %s, want cached 1", view)
+		}
+	}
+	if got := loads.Load(); got != 2 {
+		t.Fatalf("loads = %d, want a single reload", got)
+	}
+	close(release)
+	<-refreshed
+	if view, _ := g.Get("k1"); view.String() != "2" {
+		t.Fatalf("Get(k1) = %s, want refreshed 2", view)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	var fail atomic.Bool
+	g := go_cache.NewGroup("stale-if-error", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if fail.Load() {
+				return nil, fmt.Errorf("db down")
+			}
+			return []byte("v1"), nil
+		}))
+	// values expire as soon as they are loaded
+	g.SetTTL(time.Nanosecond)
+	g.SetStaleIfError(time.Hour)
+
+	g.Get("k1")
+	fail.Store(true)
+	if view, err := g.Get("k1"); err != nil || view.String() != "v1" {
+		t.Fatalf("Get(k1) = %s, %v, want stale v1", view, err)
+	}
+
+	g.SetStaleIfError(time.Nanosecond)
+	if _, err := g.Get("k1"); err == nil {
+		t.Fatal("expect error once the value is too stale")
+	}
+}
@@ -0,0 +1,687 @@
+package go_cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_cache"
+	"go_cache/consistenthash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ********************** cluster harness *************************
+
+// cluster runs nodes in process, each with its own registry, talking over
+// HTTP through a transport injecting the faults set on the cluster.
+type cluster struct {
+	nodes  []*node
+	faults *faults
+
+	dbMu   sync.Mutex
+	db     map[string]string        // source of the group "scores" on every node
+	blocks map[string]chan struct{} // loads of a key wait until its channel is closed
+}
+
+type node struct {
+	url      string
+	registry *go_cache.Registry
+	pool     *go_cache.HTTPPool
+	srv      *httptest.Server
+	group    *go_cache.Group
+	loads    atomic.Int64 // from the database
+	down     atomic.Bool
+}
+
+// faults on requests between nodes, keyed by the base URLs of the nodes
+type faults struct {
+	mu         sync.Mutex
+	latency    map[string]time.Duration // added to requests to a node
+	drops      map[string]int           // requests to a node still to drop, -1 for all
+	partitions map[[2]string]bool       // pairs of nodes unable to talk, both ways
+}
+
+func newCluster(t *testing.T, n int) *cluster {
+	c := &cluster{
+		faults: &faults{
+			latency:    make(map[string]time.Duration),
+			drops:      make(map[string]int),
+			partitions: make(map[[2]string]bool),
+		},
+		db:     make(map[string]string),
+		blocks: make(map[string]chan struct{}),
+	}
+
+	var urls []string
+	for i := 0; i < n; i++ {
+		nd := &node{registry: go_cache.NewRegistry()}
+		nd.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if nd.down.Load() {
+				crash(w)
+				return
+			}
+			nd.pool.ServeHTTP(w, r)
+		}))
+		nd.url = nd.srv.URL
+		t.Cleanup(nd.srv.Close)
+		urls = append(urls, nd.url)
+		c.nodes = append(c.nodes, nd)
+	}
+
+	for _, nd := range c.nodes {
+		nd := nd
+		nd.pool = go_cache.NewHTTPPool(nd.url)
+		nd.pool.SetRegistry(nd.registry)
+		nd.pool.SetTransport(&faultTransport{from: nd.url, faults: c.faults, base: http.DefaultTransport})
+		nd.pool.Set(urls...)
+		nd.group = nd.registry.NewGroup("scores", 0, go_cache.GetterFunc(
+			func(key string) ([]byte, error) {
+				nd.loads.Add(1)
+				c.dbMu.Lock()
+				block := c.blocks[key]
+				c.dbMu.Unlock()
+				if block != nil {
+					<-block
+				}
+				c.dbMu.Lock()
+				defer c.dbMu.Unlock()
+				if v, ok := c.db[key]; ok {
+					return []byte(v), nil
+				}
+				return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+			}))
+		nd.group.RegisterPeers(nd.pool)
+	}
+	return c
+}
+
+// crash drops the connection without a response, like a dead process
+func crash(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func (c *cluster) put(key, value string) {
+	c.dbMu.Lock()
+	defer c.dbMu.Unlock()
+	c.db[key] = value
+}
+
+// block makes the loads of key wait until the returned func is called
+func (c *cluster) block(key string) (unblock func()) {
+	ch := make(chan struct{})
+	c.dbMu.Lock()
+	c.blocks[key] = ch
+	c.dbMu.Unlock()
+	return func() {
+		c.dbMu.Lock()
+		delete(c.blocks, key)
+		c.dbMu.Unlock()
+		close(ch)
+	}
+}
+
+// owner returns the node owning key for the given peers, all nodes by default
+func (c *cluster) owner(key string, nodes ...*node) *node {
+	if len(nodes) == 0 {
+		nodes = c.nodes
+	}
+	m := consistenthash.New(go_cache.DefaultReplicas, nil)
+	for _, nd := range nodes {
+		m.Add(nd.url)
+	}
+	url := m.Get(key)
+	for _, nd := range nodes {
+		if nd.url == url {
+			return nd
+		}
+	}
+	return nil
+}
+
+// keyOwnedBy returns a key owned by nd, distinct from except
+func (c *cluster) keyOwnedBy(nd *node, except ...string) string {
+next:
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if c.owner(key) != nd {
+			continue
+		}
+		for _, k := range except {
+			if k == key {
+				continue next
+			}
+		}
+		return key
+	}
+}
+
+func (c *cluster) totalLoads() int64 {
+	var n int64
+	for _, nd := range c.nodes {
+		n += nd.loads.Load()
+	}
+	return n
+}
+
+// crash makes nd drop every connection
+func (nd *node) crash() {
+	nd.down.Store(true)
+}
+
+// restart brings nd back with an empty cache
+func (nd *node) restart() {
+	nd.registry.Purge()
+	nd.down.Store(false)
+}
+
+func (f *faults) setLatency(to *node, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency[to.url] = d
+}
+
+// drop fails the next n requests to a node, n < 0 fails all
+func (f *faults) drop(to *node, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drops[to.url] = n
+}
+
+func (f *faults) partition(a, b *node) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.partitions[[2]string{a.url, b.url}] = true
+	f.partitions[[2]string{b.url, a.url}] = true
+}
+
+func (f *faults) heal() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = make(map[string]time.Duration)
+	f.drops = make(map[string]int)
+	f.partitions = make(map[[2]string]bool)
+}
+
+// check returns the injected failure and latency of a request
+func (f *faults) check(from, to string) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.partitions[[2]string{from, to}] {
+		return 0, fmt.Errorf("partitioned from %s", to)
+	}
+	if n := f.drops[to]; n != 0 {
+		if n > 0 {
+			f.drops[to] = n - 1
+		}
+		return 0, fmt.Errorf("request to %s dropped", to)
+	}
+	return f.latency[to], nil
+}
+
+type faultTransport struct {
+	from   string
+	faults *faults
+	base   http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	to := req.URL.Scheme + "://" + req.URL.Host
+	delay, err := t.faults.check(t.from, to)
+	if err != nil {
+		return nil, err
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// ********************** cluster tests *************************
+
+func TestClusterOwnerLoads(t *testing.T) {
+	c := newCluster(t, 3)
+	key := c.keyOwnedBy(c.nodes[0])
+	c.put(key, "630")
+
+	// every node gets the value from the owner, which loads it once
+	for _, nd := range c.nodes {
+		if v, err := nd.group.Get(key); err != nil || v.String() != "630" {
+			t.Fatalf("Get(%s) on %s = %s, %v", key, nd.url, v, err)
+		}
+	}
+	if loads := c.nodes[0].loads.Load(); loads != 1 || c.totalLoads() != 1 {
+		t.Fatalf("owner loads = %d, total %d, want 1", loads, c.totalLoads())
+	}
+}
+
+func TestClusterFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault func(c *cluster, owner *node)
+	}{
+		{"crash", func(c *cluster, owner *node) { owner.crash() }},
+		{"drop", func(c *cluster, owner *node) { c.faults.drop(owner, -1) }},
+		{"partition", func(c *cluster, owner *node) { c.faults.partition(c.nodes[1], owner) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCluster(t, 3)
+			owner := c.nodes[0]
+			key := c.keyOwnedBy(owner)
+			c.put(key, "630")
+			tt.fault(c, owner)
+
+			// the owner is unreachable, load from the database instead of failing
+			if v, err := c.nodes[1].group.Get(key); err != nil || v.String() != "630" {
+				t.Fatalf("Get(%s) = %s, %v", key, v, err)
+			}
+			if s := c.nodes[1].group.Stats(); s.PeerErrors != 1 || s.LocalLoads != 1 {
+				t.Fatalf("stats = %+v, want a peer error and a local load", s)
+			}
+		})
+	}
+}
+
+func TestClusterSlowOwner(t *testing.T) {
+	c := newCluster(t, 3)
+	owner := c.nodes[0]
+	key := c.keyOwnedBy(owner)
+	c.put(key, "630")
+	c.faults.setLatency(owner, time.Second)
+
+	// the caller stops waiting at its deadline, the load goes on for others
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if v, err := c.nodes[1].group.GetContext(ctx, key); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext(%s) = %s, %v, want the deadline exceeded", key, v, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("GetContext took %v, want the wait abandoned", elapsed)
+	}
+	if v, err := c.nodes[1].group.Get(key); err != nil || v.String() != "630" {
+		t.Fatalf("Get(%s) = %s, %v", key, v, err)
+	}
+	if s := c.nodes[1].group.Stats(); s.LoadsDeduped != 1 {
+		t.Fatalf("loads = %d, want the abandoned load shared", s.LoadsDeduped)
+	}
+}
+
+func TestClusterWrites(t *testing.T) {
+	c := newCluster(t, 3)
+	owner := c.nodes[0]
+	key := c.keyOwnedBy(owner)
+	c.put(key, "630")
+
+	// writes on any node are executed by the owner and seen by every node
+	v1, err := c.nodes[1].group.Set(key, []byte("700"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range c.nodes {
+		if v, err := nd.group.Get(key); err != nil || v.String() != "700" || v.Version() != v1.Version() {
+			t.Fatalf("Get(%s) on %s = %s@%d, %v, want 700@%d", key, nd.url, v, v.Version(), err, v1.Version())
+		}
+	}
+
+	// a compare-and-set racing on two nodes succeeds once
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for _, nd := range c.nodes[1:] {
+		wg.Add(1)
+		go func(nd *node) {
+			defer wg.Done()
+			if _, err := nd.group.CompareAndSet(key, v1.Version(), []byte(nd.url)); err == nil {
+				wins.Add(1)
+			} else if !errors.Is(err, go_cache.ErrVersionMismatch) {
+				t.Errorf("CompareAndSet on %s: %v", nd.url, err)
+			}
+		}(nd)
+	}
+	wg.Wait()
+	if wins.Load() != 1 {
+		t.Fatalf("%d compare-and-sets won, want 1", wins.Load())
+	}
+
+	// writes fail rather than forking versions while the owner is down
+	owner.crash()
+	if _, err := c.nodes[1].group.Set(key, []byte("800")); err == nil {
+		t.Fatal("expect Set to fail while the owner is down")
+	}
+}
+
+func TestClusterInvalidate(t *testing.T) {
+	c := newCluster(t, 3)
+	a, b, other := c.nodes[0], c.nodes[1], c.nodes[2]
+	key := c.keyOwnedBy(other)
+	c.put(key, "630")
+
+	// cache key on every node, locally since the owner is unreachable
+	other.crash()
+	for _, nd := range []*node{a, b} {
+		nd.group.Get(key)
+	}
+	other.restart()
+	other.group.Get(key)
+	cached := func(nd *node) bool {
+		hits := nd.group.Stats().CacheHits
+		nd.group.Get(key)
+		return nd.group.Stats().CacheHits == hits+1
+	}
+
+	c.put(key, "700")
+	c.faults.drop(b, 2) // delivered on the third attempt
+	if err := a.group.Invalidate(key); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	other.crash() // load locally again
+	for _, nd := range []*node{a, b} {
+		if v, _ := nd.group.Get(key); v.String() != "700" {
+			t.Fatalf("Get(%s) on %s = %s after invalidation, want 700", key, nd.url, v)
+		}
+	}
+	other.restart()
+
+	// an invalidation lost in a partition is reported, and the gap it
+	// leaves makes the peer drop everything on the next one
+	c.faults.partition(a, b)
+	if err := a.group.Invalidate(key); err == nil || !strings.Contains(err.Error(), b.url) {
+		t.Fatalf("Invalidate across partition err = %v, want it to name %s", err, b.url)
+	}
+	if !cached(b) {
+		t.Fatalf("expect %s still cached on %s", key, b.url)
+	}
+	c.faults.heal()
+	if err := a.group.Invalidate("unrelated"); err != nil {
+		t.Fatalf("Invalidate after heal: %v", err)
+	}
+	if cached(b) {
+		t.Fatalf("expect %s purged from %s after the gap", key, b.url)
+	}
+}
+
+func TestClusterHotKeys(t *testing.T) {
+	c := newCluster(t, 3)
+	a, owner := c.nodes[0], c.nodes[1]
+	hot := c.keyOwnedBy(owner)
+	cold := c.keyOwnedBy(owner, hot)
+	c.put(hot, "630")
+	c.put(cold, "589")
+	a.group.SetHotKeys(go_cache.HotKeyOptions{Replicate: 0.5})
+
+	get := func(key, want string) {
+		t.Helper()
+		if v, err := a.group.Get(key); err != nil || v.String() != want {
+			t.Fatalf("Get(%s) = %s, %v, want %s", key, v, err, want)
+		}
+	}
+	get(cold, "589")
+	for i := 0; i < 20; i++ {
+		get(hot, "630")
+	}
+	// replicated once got 16 times, and over half of the gets
+	if s := a.group.Stats(); s.HotHits != 4 || s.PeerLoads != 17 {
+		t.Fatalf("stats = %+v, want 4 hot hits", s)
+	}
+	if n := owner.group.Stats().ServerRequests; n != 17 {
+		t.Fatalf("owner served %d gets, want 17", n)
+	}
+	info := a.group.Info()
+	if info.HotItems != 1 || len(info.HotKeys) != 2 || info.HotKeys[0] != (go_cache.HotKey{Key: hot, Count: 20}) {
+		t.Fatalf("info = %+v, want %s replicated and hottest", info, hot)
+	}
+
+	// the replica is dropped by invalidations and writes through the node
+	c.put(hot, "700")
+	if err := owner.group.Invalidate(hot); err != nil {
+		t.Fatal(err)
+	}
+	get(hot, "700")
+	if _, err := a.group.Set(hot, []byte("800")); err != nil {
+		t.Fatal(err)
+	}
+	get(hot, "800")
+	if s := a.group.Stats(); s.HotHits != 4 {
+		t.Fatalf("hot hits = %d after invalidation and write, want 4", s.HotHits)
+	}
+}
+
+func TestClusterRebalance(t *testing.T) {
+	c := newCluster(t, 4)
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		c.put(keys[i], keys[i])
+	}
+	for _, key := range keys {
+		c.nodes[0].group.Get(key)
+	}
+	if loads := c.totalLoads(); loads != int64(len(keys)) {
+		t.Fatalf("loads = %d, want %d", loads, len(keys))
+	}
+
+	// remove the last node from every peer list
+	removed := c.nodes[3]
+	remaining := c.nodes[:3]
+	var urls []string
+	for _, nd := range remaining {
+		urls = append(urls, nd.url)
+	}
+	for _, nd := range remaining {
+		nd.pool.Set(urls...)
+	}
+	removed.crash()
+
+	// only the keys of the removed node move and are loaded again
+	var moved int64
+	for _, key := range keys {
+		if c.owner(key) == removed {
+			moved++
+		} else if c.owner(key) != c.owner(key, remaining...) {
+			t.Fatalf("%s moved from %s to %s", key, c.owner(key).url, c.owner(key, remaining...).url)
+		}
+		if v, err := c.nodes[1].group.Get(key); err != nil || v.String() != key {
+			t.Fatalf("Get(%s) = %s, %v", key, v, err)
+		}
+	}
+	if moved == 0 || moved != removed.loads.Load() {
+		t.Fatalf("%d of %d keys moved, want the %d loaded by the removed node", moved, len(keys), removed.loads.Load())
+	}
+	if loads := c.totalLoads(); loads != int64(len(keys))+moved {
+		t.Fatalf("loads = %d, want %d", loads, int64(len(keys))+moved)
+	}
+}
+
+func TestClusterLeases(t *testing.T) {
+	c := newCluster(t, 4)
+	owner := c.nodes[0]
+	hot := c.keyOwnedBy(owner)
+	slow := c.keyOwnedBy(owner, hot)
+	c.put(hot, "v")
+	c.put(slow, "v")
+
+	// the owner sheds its loads while a slow one runs
+	owner.group.SetMaxLoads(1)
+	unblockSlow := c.block(slow)
+	defer unblockSlow()
+	go owner.group.Get(slow)
+	for owner.loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the first node granted the lease loads the key, the others wait for it
+	unblockHot := c.block(hot)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(c.nodes)-1)
+	versions := make(chan uint64, len(c.nodes)-1)
+	for _, nd := range c.nodes[1:] {
+		wg.Add(1)
+		go func(nd *node) {
+			defer wg.Done()
+			v, err := nd.group.Get(hot)
+			if err != nil || v.String() != "v" {
+				errs <- fmt.Errorf("Get(%s) on %s = %s, %v", hot, nd.url, v, err)
+			}
+			versions <- v.Version()
+		}(nd)
+	}
+	for owner.group.Stats().LeaseWaits < int64(len(c.nodes)-2) {
+		time.Sleep(time.Millisecond)
+	}
+	unblockHot()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if loads := c.totalLoads(); loads != 2 {
+		t.Fatalf("database loads = %d, want one for each key", loads)
+	}
+	if s := owner.group.Stats(); s.LeasesGranted != 1 {
+		t.Fatalf("owner stats = %+v, want a single lease", s)
+	}
+	// the holder too got the version the owner cached
+	close(versions)
+	cached, _, _ := owner.group.Peek(hot)
+	for version := range versions {
+		if version != cached.Version() {
+			t.Fatalf("version got %d, want %d cached by the owner", version, cached.Version())
+		}
+	}
+	// the holder filled the owner, later gets are served by it
+	if v, err := c.nodes[1].group.Get(hot); err != nil || v.String() != "v" || c.totalLoads() != 2 {
+		t.Fatalf("Get(%s) = %s, %v after the lease", hot, v, err)
+	}
+}
+
+func TestClusterLeaseRevoked(t *testing.T) {
+	c := newCluster(t, 2)
+	owner, holder := c.nodes[0], c.nodes[1]
+	key := c.keyOwnedBy(owner)
+	slow := c.keyOwnedBy(owner, key)
+	c.put(key, "old")
+	c.put(slow, "v")
+
+	owner.group.SetMaxLoads(1)
+	unblockSlow := c.block(slow)
+	defer unblockSlow()
+	go owner.group.Get(slow)
+	for owner.loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	unblock := c.block(key)
+	done := make(chan go_cache.ByteView)
+	go func() {
+		v, _ := holder.group.Get(key)
+		done <- v
+	}()
+	for holder.loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a write while the holder loads makes its value outdated
+	if _, err := owner.group.Set(key, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	unblock()
+	if v := <-done; v.String() != "old" {
+		t.Fatalf("holder got %s, want its own load", v)
+	}
+	if v, err := owner.group.Get(key); err != nil || v.String() != "new" {
+		t.Fatalf("owner Get(%s) = %s, %v, want the write kept", key, v, err)
+	}
+}
+
+func TestClusterScan(t *testing.T) {
+	c := newCluster(t, 3)
+	var want []string
+	for i := 0; i < 9; i++ {
+		key := fmt.Sprintf("user:42:%d", i)
+		want = append(want, key)
+		if _, err := c.nodes[0].group.Set(key, []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.nodes[1].group.Set("user:7:0", []byte("v"))
+	for _, nd := range c.nodes {
+		nd.group.EnableKeyIndex()
+	}
+	c.faults.partition(c.nodes[0], c.nodes[2])
+
+	// pages of the keys of every node, missing the unreachable one
+	admin := c.nodes[0].url + "/_go_cache/_admin/groups/scores/keys"
+	var got []string
+	var res go_cache.ScanResult
+	for cursor := ""; ; cursor = res.Next {
+		res = go_cache.ScanResult{}
+		getJSON(t, admin+"?pattern=user:42:*&limit=2&cursor="+cursor, &res)
+		if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], c.nodes[2].url) {
+			t.Fatalf("errors = %q, want the partitioned node", res.Errors)
+		}
+		got = append(got, res.Keys...)
+		if res.Next == "" {
+			break
+		}
+	}
+	var reachable []string
+	for _, key := range want {
+		if c.owner(key) != c.nodes[2] {
+			reachable = append(reachable, key)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(reachable, ",") {
+		t.Fatalf("scanned %q, want %q", got, reachable)
+	}
+
+	// deletes by pattern reach every node
+	c.faults.heal()
+	req, _ := http.NewRequest(http.MethodDelete, admin+"?pattern=user:42:*", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = go_cache.ScanResult{}
+	json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if res.Deleted != len(want) || len(res.Errors) != 0 {
+		t.Fatalf("delete = %+v, want %d keys deleted", res, len(want))
+	}
+	res = go_cache.ScanResult{}
+	getJSON(t, admin, &res)
+	if strings.Join(res.Keys, ",") != "user:7:0" {
+		t.Fatalf("keys after delete = %q", res.Keys)
+	}
+}
+
+func getJSON(t *testing.T, url string, v any) {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", url, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
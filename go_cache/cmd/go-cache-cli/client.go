@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_cache"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const basePath = "/_go_cache/"
+
+// client talks to the HTTPPool of one node
+type client struct {
+	node     string // base URL, e.g. "http://localhost:8001"
+	http     *http.Client
+	replicas int // virtual nodes per peer on the ring of the nodes
+}
+
+func newClient(node string) *client {
+	return &client{
+		node:     strings.TrimSuffix(node, "/"),
+		http:     &http.Client{Timeout: 10 * time.Second},
+		replicas: go_cache.DefaultReplicas,
+	}
+}
+
+func (c *client) keyURL(group, key string, query url.Values) string {
+	return c.node + basePath + go_cache.KeyPath(group, key, query)
+}
+
+// value is a cached value with its version
+type value struct {
+	data    []byte
+	version string
+}
+
+func (c *client) get(group, key string) (*value, error) {
+	res, err := c.http.Get(c.keyURL(group, key, nil))
+	if err != nil {
+		return nil, err
+	}
+	return readValue(res)
+}
+
+func (c *client) set(group, key string, data []byte, version string) (*value, error) {
+	query := make(url.Values)
+	if version != "" {
+		query.Set("version", version)
+	}
+	req, err := http.NewRequest(http.MethodPut, c.keyURL(group, key, query), strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return readValue(res)
+}
+
+func (c *client) delete(group, key string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.keyURL(group, key, nil), nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	_, err = readBody(res)
+	return err
+}
+
+func (c *client) groups() ([]go_cache.GroupInfo, error) {
+	var infos []go_cache.GroupInfo
+	err := c.getJSON(basePath+"_admin/groups", &infos)
+	return infos, err
+}
+
+func (c *client) group(name string) (go_cache.GroupInfo, error) {
+	var info go_cache.GroupInfo
+	err := c.getJSON(basePath+"_admin/groups/"+url.PathEscape(name), &info)
+	return info, err
+}
+
+func (c *client) peers() (go_cache.PoolInfo, error) {
+	var info go_cache.PoolInfo
+	err := c.getJSON(basePath+"_admin/peers", &info)
+	return info, err
+}
+
+func (c *client) keys(group string, limit int) ([]string, error) {
+	return go_cache.PeerKeys(c.node, group, limit).Keys()
+}
+
+func (c *client) getJSON(path string, v any) error {
+	res, err := c.http.Get(c.node + path)
+	if err != nil {
+		return err
+	}
+	body, err := readBody(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func readValue(res *http.Response) (*value, error) {
+	body, err := readBody(res)
+	if err != nil {
+		return nil, err
+	}
+	return &value{data: body, version: res.Header.Get("X-Go-Cache-Version")}, nil
+}
+
+// readBody reads and closes the body, turning error statuses into errors
+func readBody(res *http.Response) ([]byte, error) {
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func parseLimit(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(args[0])
+}
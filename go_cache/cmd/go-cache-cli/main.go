@@ -0,0 +1,210 @@
+// go-cache-cli inspects and operates a go_cache cluster through the HTTPPool
+// of one of its nodes.
+//
+//	go-cache-cli [-node http://localhost:8001] [-replicas 50] <command> [arguments]
+//
+// The owner command locates keys on a ring hashed by crc32, the default of
+// HTTPPool.SetHash, with the virtual nodes per peer given by -replicas.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go_cache"
+	"go_cache/consistenthash"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = `usage: go-cache-cli [-node URL] [-replicas N] <command> [arguments]
+
+commands:
+  get <group> <key>                     print a value
+  set <group> <key> <value|-> [version] store a value, "-" reads stdin,
+                                        a version makes it a compare-and-set
+  delete <group> <key>                  drop a key from every node
+  groups                                list groups of the node
+  stats [group]                         show counters of groups
+  hot <group>                           show the most frequently got keys
+  peers                                 list peers of the node
+  owner <key>                           show which peer owns a key, on a
+                                        crc32 ring of N virtual nodes per peer
+  keys <group> [limit]                  dump keys cached by the node
+`
+
+func main() {
+	node := flag.String("node", "http://localhost:8001", "base URL of the node to talk to")
+	verbose := flag.Bool("v", false, "print versions of values")
+	replicas := flag.Int("replicas", go_cache.DefaultReplicas, "virtual nodes per peer the nodes hash keys with")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := newClient(*node)
+	c.replicas = *replicas
+	if err := run(c, os.Stdout, *verbose, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "go-cache-cli:", err)
+		os.Exit(1)
+	}
+}
+
+func run(c *client, out io.Writer, verbose bool, cmd string, args []string) error {
+	need := func(n int) error {
+		if len(args) < n {
+			return fmt.Errorf("%s needs %d arguments\n\n%s", cmd, n, usage)
+		}
+		return nil
+	}
+
+	switch cmd {
+	case "get":
+		if err := need(2); err != nil {
+			return err
+		}
+		v, err := c.get(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		return printValue(out, v, verbose)
+
+	case "set":
+		if err := need(3); err != nil {
+			return err
+		}
+		data := []byte(args[2])
+		if args[2] == "-" {
+			var err error
+			if data, err = io.ReadAll(os.Stdin); err != nil {
+				return err
+			}
+		}
+		var version string
+		if len(args) > 3 {
+			version = args[3]
+		}
+		v, err := c.set(args[0], args[1], data, version)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "version", v.version)
+		return nil
+
+	case "delete":
+		if err := need(2); err != nil {
+			return err
+		}
+		return c.delete(args[0], args[1])
+
+	case "groups":
+		infos, err := c.groups()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tITEMS\tBYTES\tCACHE_BYTES\tTTL")
+		for _, g := range infos {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", g.Name, g.Items, g.Bytes, g.CacheBytes, g.TTL)
+		}
+		return w.Flush()
+
+	case "stats":
+		var infos []go_cache.GroupInfo
+		var err error
+		if len(args) > 0 {
+			var info go_cache.GroupInfo
+			info, err = c.group(args[0])
+			infos = append(infos, info)
+		} else {
+			infos, err = c.groups()
+		}
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tGETS\tHITS\tSTALE\tLOADS\tPEER_LOADS\tPEER_ERRS\tLOCAL_LOADS\tLOCAL_ERRS\tSERVED")
+		for _, g := range infos {
+			s := g.Stats
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", g.Name, s.Gets, s.CacheHits, s.StaleHits,
+				s.Loads, s.PeerLoads, s.PeerErrors, s.LocalLoads, s.LocalLoadErrs, s.ServerRequests)
+		}
+		return w.Flush()
+
+	case "hot":
+		if err := need(1); err != nil {
+			return err
+		}
+		info, err := c.group(args[0])
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tGETS\tERR")
+		for _, k := range info.HotKeys {
+			fmt.Fprintf(w, "%q\t%d\t%d\n", k.Key, k.Count, k.Err)
+		}
+		return w.Flush()
+
+	case "peers":
+		info, err := c.peers()
+		if err != nil {
+			return err
+		}
+		for _, peer := range info.Peers {
+			mark := ""
+			if peer == info.Self {
+				mark = " (self)"
+			}
+			fmt.Fprintln(out, peer+mark)
+		}
+		return nil
+
+	case "owner":
+		if err := need(1); err != nil {
+			return err
+		}
+		info, err := c.peers()
+		if err != nil {
+			return err
+		}
+		if len(info.Peers) == 0 {
+			fmt.Fprintln(out, info.Self)
+			return nil
+		}
+		// same ring as HTTPPool.Set, unless the nodes use another hash
+		ring := consistenthash.New(c.replicas, nil)
+		ring.Add(info.Peers...)
+		fmt.Fprintln(out, ring.Get(args[0]))
+		return nil
+
+	case "keys":
+		if err := need(1); err != nil {
+			return err
+		}
+		limit, err := parseLimit(args[1:])
+		if err != nil {
+			return fmt.Errorf("bad limit: %w", err)
+		}
+		keys, err := c.keys(args[0], limit)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Fprintln(out, key)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+}
+
+func printValue(out io.Writer, v *value, verbose bool) error {
+	if verbose {
+		fmt.Fprintln(out, "version", v.version)
+	}
+	_, err := out.Write(append(v.data, '\n'))
+	return err
+}
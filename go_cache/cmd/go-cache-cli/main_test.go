@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go_cache"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	g := go_cache.NewGroup("scores", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "Tom" {
+				return []byte("630"), nil
+			}
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	g.SetHotKeys(go_cache.HotKeyOptions{})
+
+	pool := go_cache.NewHTTPPool("self")
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+	pool.Set("self")
+	c := newClient(srv.URL)
+
+	tests := []struct {
+		args []string
+		want string // substring of the output
+		err  bool
+	}{
+		{[]string{"get", "scores", "Tom"}, "630\n", false},
+		{[]string{"get", "scores", "Sam"}, "", true},
+		{[]string{"set", "scores", "Sam", "567"}, "version ", false},
+		{[]string{"set", "scores", "Sam", "568", "1"}, "", true},
+		{[]string{"get", "scores", "Sam"}, "567\n", false},
+		{[]string{"keys", "scores"}, "Sam\nTom\n", false},
+		{[]string{"groups"}, "scores", false},
+		{[]string{"stats", "scores"}, "scores", false},
+		{[]string{"hot", "scores"}, "\"Sam\"  2", false},
+		{[]string{"peers"}, "self (self)\n", false},
+		{[]string{"owner", "Tom"}, "self\n", false},
+		{[]string{"delete", "scores", "Sam"}, "", false},
+		{[]string{"keys", "scores"}, "Tom\n", false},
+		{[]string{"get", "scores"}, "", true},
+		{[]string{"fly"}, "", true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := run(c, &out, false, tt.args[0], tt.args[1:])
+		if (err != nil) != tt.err {
+			t.Fatalf("%v: err = %v", tt.args, err)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Fatalf("%v: output %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"go_cache"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// newBackend creates the Getter described by spec:
+//
+//	dir:/var/lib/data           value of key is the content of file /var/lib/data/<key>
+//	http://origin:8080/values/  value of key is the body of GET http://origin:8080/values/<key>
+//	static:Tom=630,Jack=589     fixed key value pairs
+func newBackend(spec string) (go_cache.Getter, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "dir":
+		return dirBackend(arg), nil
+	case "http", "https":
+		return &httpBackend{
+			baseURL: spec,
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case "static":
+		return staticBackend(arg)
+	}
+	return nil, fmt.Errorf("unknown backend %q", spec)
+}
+
+// dirBackend serves files of a directory, the key is a relative file name
+type dirBackend string
+
+func (d dirBackend) Get(key string) ([]byte, error) {
+	if !filepath.IsLocal(key) {
+		return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+	}
+	return os.ReadFile(filepath.Join(string(d), key))
+}
+
+// httpBackend loads values from an origin HTTP server
+type httpBackend struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (h *httpBackend) Get(key string) ([]byte, error) {
+	res, err := h.client.Get(h.baseURL + url.PathEscape(key))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("origin returned: %v", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func staticBackend(pairs string) (go_cache.Getter, error) {
+	values := make(map[string][]byte)
+	for _, pair := range splitList(pairs) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad static pair %q, expect key=value", pair)
+		}
+		values[k] = []byte(v)
+	}
+	return go_cache.GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := values[key]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+	}), nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go_cache"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config of a cache node. The file format is line based:
+//
+//	# comment
+//	listen = :8001
+//	self   = http://localhost:8001
+//	peers  = http://localhost:8001, http://localhost:8002
+//	client_rate = 100
+//
+//	[group scores]
+//	cache_bytes = 2048
+//	ttl         = 1m
+//	max_loads   = 16
+//	disk_dir    = /var/cache/go-cache/scores
+//	disk_bytes  = 1073741824
+//	backend     = static:Tom=630,Jack=589
+type Config struct {
+	Listen          string   // address to listen, defaults to the host of Self
+	Self            string   // base URL of this node, e.g. "http://10.0.0.2:8001"
+	Peers           []string // base URLs of all nodes, including Self
+	ShutdownTimeout time.Duration
+
+	RESPListen     string // address of the Redis protocol frontend, empty disables it
+	MemcacheListen string // address of the memcached protocol frontend, empty disables it
+
+	Limits     go_cache.Limits // of requests served over HTTP
+	AdminToken string          // required by the admin requests changing groups, empty means none
+
+	MemoryLimit  int64   // bytes the budgets of the groups are sized by, 0 means GOMEMLIMIT
+	MemoryTarget float64 // fraction of MemoryLimit to stay under, 0 means the default
+
+	Groups []GroupConfig
+}
+
+type GroupConfig struct {
+	Name         string
+	CacheBytes   int64
+	TTL          time.Duration
+	RefreshAhead time.Duration
+	StaleIfError time.Duration
+	MaxLoads     int           // bound of concurrent loads from the backend, 0 means unbounded
+	LeaseTTL     time.Duration // granted to peers loading shed keys, 0 means the default
+	DiskDir      string        // directory of the disk tier, empty disables it
+	DiskBytes    int64         // byte budget of the disk tier, 0 means no limit
+	HotKeys      int           // keys tracked by gets, 0 disables tracking unless HotReplicate
+	HotReplicate float64       // share of gets above which keys of peers are replicated, 0 disables it
+	HotBytes     int64         // byte budget of the replicas, 0 means the default
+	Backend      string        // see newBackend
+}
+
+const defaultShutdownTimeout = 10 * time.Second
+
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf, err := ParseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf, nil
+}
+
+func ParseConfig(r io.Reader) (*Config, error) {
+	conf := &Config{ShutdownTimeout: defaultShutdownTimeout}
+	var group *GroupConfig
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section, ok := strings.CutSuffix(line[1:], "]")
+			kind, name, _ := strings.Cut(strings.TrimSpace(section), " ")
+			name = strings.TrimSpace(name)
+			if !ok || kind != "group" || name == "" {
+				return nil, fmt.Errorf("line %d: expect [group <name>], got %s", lineNo, line)
+			}
+			conf.Groups = append(conf.Groups, GroupConfig{Name: name})
+			group = &conf.Groups[len(conf.Groups)-1]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expect key = value, got %s", lineNo, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		if group == nil {
+			err = conf.set(key, value)
+		} else {
+			err = group.set(key, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (c *Config) set(key, value string) (err error) {
+	switch key {
+	case "listen":
+		c.Listen = value
+	case "self":
+		c.Self = value
+	case "peers":
+		c.Peers = splitList(value)
+	case "shutdown_timeout":
+		c.ShutdownTimeout, err = parseDuration(key, value)
+	case "resp_listen":
+		c.RESPListen = value
+	case "memcache_listen":
+		c.MemcacheListen = value
+	case "rate":
+		c.Limits.Rate, err = parseRate(key, value)
+	case "burst":
+		c.Limits.Burst, err = parseCount(key, value)
+	case "client_rate":
+		c.Limits.ClientRate, err = parseRate(key, value)
+	case "client_burst":
+		c.Limits.ClientBurst, err = parseCount(key, value)
+	case "admin_token":
+		c.AdminToken = value
+	case "memory_limit":
+		if c.MemoryLimit, err = strconv.ParseInt(value, 10, 64); err != nil || c.MemoryLimit < 0 {
+			return fmt.Errorf("bad memory_limit %q", value)
+		}
+	case "memory_target":
+		if c.MemoryTarget, err = strconv.ParseFloat(value, 64); err != nil || c.MemoryTarget <= 0 || c.MemoryTarget > 1 {
+			return fmt.Errorf("bad memory_target %q, expect a fraction in (0, 1]", value)
+		}
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return err
+}
+
+func (g *GroupConfig) set(key, value string) (err error) {
+	switch key {
+	case "cache_bytes":
+		if g.CacheBytes, err = strconv.ParseInt(value, 10, 64); err != nil || g.CacheBytes < 0 {
+			return fmt.Errorf("bad cache_bytes %q", value)
+		}
+	case "ttl":
+		g.TTL, err = parseDuration(key, value)
+	case "refresh_ahead":
+		g.RefreshAhead, err = parseDuration(key, value)
+	case "stale_if_error":
+		g.StaleIfError, err = parseDuration(key, value)
+	case "max_loads":
+		g.MaxLoads, err = parseCount(key, value)
+	case "lease_ttl":
+		g.LeaseTTL, err = parseDuration(key, value)
+	case "disk_dir":
+		g.DiskDir = value
+	case "disk_bytes":
+		if g.DiskBytes, err = strconv.ParseInt(value, 10, 64); err != nil || g.DiskBytes < 0 {
+			return fmt.Errorf("bad disk_bytes %q", value)
+		}
+	case "hot_keys":
+		g.HotKeys, err = parseCount(key, value)
+	case "hot_replicate":
+		if g.HotReplicate, err = strconv.ParseFloat(value, 64); err != nil || g.HotReplicate < 0 || g.HotReplicate > 1 {
+			return fmt.Errorf("bad hot_replicate %q, expect a fraction in [0, 1]", value)
+		}
+	case "hot_bytes":
+		if g.HotBytes, err = strconv.ParseInt(value, 10, 64); err != nil || g.HotBytes < 0 {
+			return fmt.Errorf("bad hot_bytes %q", value)
+		}
+	case "backend":
+		g.Backend = value
+	default:
+		return fmt.Errorf("unknown key %q in group %s", key, g.Name)
+	}
+	return err
+}
+
+// ApplyEnv overrides the node settings by GO_CACHE_LISTEN, GO_CACHE_SELF,
+// GO_CACHE_PEERS and GO_CACHE_ADMIN_TOKEN
+func (c *Config) ApplyEnv(getenv func(string) string) {
+	if v := getenv("GO_CACHE_LISTEN"); v != "" {
+		c.Listen = v
+	}
+	if v := getenv("GO_CACHE_SELF"); v != "" {
+		c.Self = v
+	}
+	if v := getenv("GO_CACHE_PEERS"); v != "" {
+		c.Peers = splitList(v)
+	}
+	if v := getenv("GO_CACHE_ADMIN_TOKEN"); v != "" {
+		c.AdminToken = v
+	}
+}
+
+// Validate checks the config and fills the defaults
+func (c *Config) Validate() error {
+	if c.Self == "" {
+		return fmt.Errorf("self is required")
+	}
+	u, err := url.Parse(c.Self)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("bad self %q, expect a base URL like http://localhost:8001", c.Self)
+	}
+	if c.Listen == "" {
+		c.Listen = u.Host
+	}
+	if len(c.Peers) == 0 {
+		c.Peers = []string{c.Self}
+	}
+	if len(c.Groups) == 0 {
+		return fmt.Errorf("no group configured")
+	}
+	for _, g := range c.Groups {
+		if g.Backend == "" {
+			return fmt.Errorf("group %s: backend is required", g.Name)
+		}
+		if g.DiskBytes > 0 && g.DiskDir == "" {
+			return fmt.Errorf("group %s: disk_bytes needs disk_dir", g.Name)
+		}
+		if g.HotBytes > 0 && g.HotReplicate == 0 {
+			return fmt.Errorf("group %s: hot_bytes needs hot_replicate", g.Name)
+		}
+	}
+	return nil
+}
+
+// splitList splits values separated by commas or spaces
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func parseDuration(key, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad %s %q", key, value)
+	}
+	return d, nil
+}
+
+// parseRate parses requests per second, 0 means unlimited
+func parseRate(key, value string) (float64, error) {
+	r, err := strconv.ParseFloat(value, 64)
+	if err != nil || r < 0 {
+		return 0, fmt.Errorf("bad %s %q", key, value)
+	}
+	return r, nil
+}
+
+func parseCount(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad %s %q", key, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"go_cache"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(`
+# node
+self  = http://localhost:8001
+peers = http://localhost:8001, http://localhost:8002
+client_rate  = 100
+client_burst = 10
+admin_token  = s3cret
+memory_limit = 1048576
+
+[group scores]
+cache_bytes = 2048
+ttl         = 1m
+max_loads   = 4
+lease_ttl   = 5s
+disk_dir    = /tmp/scores
+disk_bytes  = 4096
+hot_keys    = 32
+hot_replicate = 0.05
+backend     = static:Tom=630
+
+[group files]
+backend = dir:/tmp
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		Listen:          "localhost:8001",
+		Self:            "http://localhost:8001",
+		Peers:           []string{"http://localhost:8001", "http://localhost:8002"},
+		ShutdownTimeout: defaultShutdownTimeout,
+		Limits:          go_cache.Limits{ClientRate: 100, ClientBurst: 10},
+		AdminToken:      "s3cret",
+		MemoryLimit:     1 << 20,
+		Groups: []GroupConfig{
+			{Name: "scores", CacheBytes: 2048, TTL: time.Minute, MaxLoads: 4, LeaseTTL: 5 * time.Second,
+				DiskDir: "/tmp/scores", DiskBytes: 4096, HotKeys: 32, HotReplicate: 0.05, Backend: "static:Tom=630"},
+			{Name: "files", Backend: "dir:/tmp"},
+		},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Fatalf("config = %+v, want %+v", conf, want)
+	}
+
+	conf.ApplyEnv(envMap{"GO_CACHE_PEERS": "a b", "GO_CACHE_ADMIN_TOKEN": "t0ken"}.Get)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(conf.Peers, want) {
+		t.Fatalf("peers from env = %v, want %v", conf.Peers, want)
+	}
+	if conf.AdminToken != "t0ken" {
+		t.Fatalf("admin token from env = %q", conf.AdminToken)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		err  string
+	}{
+		{"unknown key", "port = 1", "line 1: unknown key"},
+		{"no equal sign", "self", "line 1: expect key = value"},
+		{"bad section", "[scores]", "line 1: expect [group <name>]"},
+		{"bad duration", "[group g]\nttl = soon", "line 2: bad ttl"},
+		{"bad bytes", "[group g]\ncache_bytes = -1", "line 2: bad cache_bytes"},
+		{"bad rate", "rate = fast", "line 1: bad rate"},
+		{"bad max loads", "[group g]\nmax_loads = -1", "line 2: bad max_loads"},
+		{"bad memory target", "memory_target = 80", "line 1: bad memory_target"},
+		{"bad hot replicate", "[group g]\nhot_replicate = 5", "line 2: bad hot_replicate"},
+		{"bad disk bytes", "[group g]\ndisk_bytes = 1G", "line 2: bad disk_bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig(strings.NewReader(tt.conf))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBackends(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "Tom"), []byte("630"), 0o644)
+
+	tests := []struct {
+		spec  string
+		key   string
+		value string
+		err   bool
+	}{
+		{"static:Tom=630,Jack=589", "Jack", "589", false},
+		{"static:Tom=630", "Sam", "", true},
+		{"dir:" + dir, "Tom", "630", false},
+		{"dir:" + dir, "../Tom", "", true},
+	}
+	for _, tt := range tests {
+		getter, err := newBackend(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := getter.Get(tt.key)
+		if (err != nil) != tt.err || string(v) != tt.value {
+			t.Errorf("%s Get(%s) = %s, %v", tt.spec, tt.key, v, err)
+		}
+	}
+
+	if _, err := newBackend("redis://localhost"); err == nil {
+		t.Fatal("expect unknown backend error")
+	}
+}
+
+type envMap map[string]string
+
+func (m envMap) Get(key string) string { return m[key] }
@@ -0,0 +1,174 @@
+// go-cache-server runs a go_cache node serving the groups of a config file.
+//
+//	go-cache-server -config node.conf [-listen :8001] [-self http://localhost:8001] [-peers a,b,c]
+//
+// Flags override the environment variables GO_CACHE_LISTEN, GO_CACHE_SELF
+// and GO_CACHE_PEERS, which override the config file.
+// GO_CACHE_ADMIN_TOKEN overrides admin_token, keeping it out of the file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go_cache"
+	"go_cache/diskcache"
+	"go_cache/internal/tcpserver"
+	"go_cache/memcache"
+	"go_cache/resp"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error("go-cache-server: " + err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var path, listen, self, peers string
+	flag.StringVar(&path, "config", "go-cache.conf", "config file")
+	flag.StringVar(&listen, "listen", "", "address to listen, overrides config")
+	flag.StringVar(&self, "self", "", "base URL of this node, overrides config")
+	flag.StringVar(&peers, "peers", "", "comma separated base URLs of all nodes, overrides config")
+	flag.Parse()
+
+	conf, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	conf.ApplyEnv(os.Getenv)
+	if listen != "" {
+		conf.Listen = listen
+	}
+	if self != "" {
+		conf.Self = self
+	}
+	if peers != "" {
+		conf.Peers = splitList(peers)
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	pool := go_cache.NewHTTPPool(conf.Self)
+	pool.Set(conf.Peers...)
+	pool.SetLimits(conf.Limits)
+	pool.SetAdminToken(conf.AdminToken)
+	for _, gc := range conf.Groups {
+		store, err := createGroup(gc, pool)
+		if err != nil {
+			return fmt.Errorf("group %s: %w", gc.Name, err)
+		}
+		if store != nil {
+			defer store.Close()
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/_go_cache/", pool)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	srv := &http.Server{Addr: conf.Listen, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	gov := go_cache.NewGovernor(go_cache.DefaultRegistry(), &go_cache.GovernorOptions{
+		Limit:  conf.MemoryLimit,
+		Target: conf.MemoryTarget,
+	})
+	go gov.Run(ctx)
+
+	errc := make(chan error, 3)
+	go func() {
+		slog.Info("go-cache-server is running", "listen", conf.Listen, "self", conf.Self, "peers", conf.Peers)
+		errc <- srv.ListenAndServe()
+	}()
+
+	// the TCP frontends serve the first group
+	var frontends []frontend
+	if conf.RESPListen != "" {
+		frontends = append(frontends, frontend{"resp", conf.RESPListen, resp.NewServer(conf.Groups[0].Name)})
+	}
+	if conf.MemcacheListen != "" {
+		frontends = append(frontends, frontend{"memcache", conf.MemcacheListen, memcache.NewServer(conf.Groups[0].Name)})
+	}
+	for _, f := range frontends {
+		go func(f frontend) {
+			slog.Info(f.name+" frontend is running", "listen", f.addr)
+			if err := f.srv.ListenAndServe(f.addr); !errors.Is(err, tcpserver.ErrServerClosed) {
+				errc <- fmt.Errorf("%s: %w", f.name, err)
+			}
+		}(f)
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	for _, f := range frontends {
+		f.srv.Close()
+	}
+
+	slog.Info("go-cache-server is shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// frontend is a TCP protocol server
+type frontend struct {
+	name string
+	addr string
+	srv  interface {
+		ListenAndServe(addr string) error
+		Close() error
+	}
+}
+
+// createGroup creates the group of gc, returning its disk tier if any
+func createGroup(gc GroupConfig, peers go_cache.PeerPicker) (*diskcache.Store, error) {
+	getter, err := newBackend(gc.Backend)
+	if err != nil {
+		return nil, err
+	}
+	g, err := go_cache.CreateGroup(gc.Name, gc.CacheBytes, getter)
+	if err != nil {
+		return nil, err
+	}
+	g.SetTTL(gc.TTL)
+	g.SetRefreshAhead(gc.RefreshAhead)
+	g.SetStaleIfError(gc.StaleIfError)
+	g.SetMaxLoads(gc.MaxLoads)
+	g.SetLeaseTTL(gc.LeaseTTL)
+	if gc.HotKeys > 0 || gc.HotReplicate > 0 {
+		g.SetHotKeys(go_cache.HotKeyOptions{Tracked: gc.HotKeys, Replicate: gc.HotReplicate, CacheBytes: gc.HotBytes})
+	}
+	g.RegisterPeers(peers)
+
+	if gc.DiskDir == "" {
+		return nil, nil
+	}
+	store, err := diskcache.Open(gc.DiskDir, gc.DiskBytes)
+	if err != nil {
+		return nil, err
+	}
+	g.SetDiskCache(store)
+	return store, nil
+}
@@ -0,0 +1,93 @@
+package go_cache
+
+import (
+	"context"
+	"go_cache/diskcache"
+	"go_cache/tracing"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// SetDiskCache adds store as a tier below the cache of the group: values
+// evicted to keep the cache within its bytes are written to it, and a miss
+// looks it up before loading the key. A value found there moves back to
+// the cache. nil removes the tier.
+//
+// The caller owns store and closes it after removing it or deleting the group.
+func (g *Group) SetDiskCache(store *diskcache.Store) {
+	g.spillOnce.Do(func() {
+		g.Subscribe(g.spill)
+	})
+	g.disk.Store(store)
+}
+
+// spill keeps the disk tier behind the cache: evicted values go down,
+// and any other change of a key leaves the cache authoritative.
+func (g *Group) spill(e Event) {
+	d := g.disk.Load()
+	if d == nil {
+		return
+	}
+	if e.Reason != EventEvict || e.Value.expired(time.Now()) {
+		d.Delete(e.Key)
+		return
+	}
+	err := d.Put(e.Key, diskcache.Entry{
+		Value:   e.Value.b,
+		Expire:  e.Value.expire,
+		Version: e.Value.version,
+		Flags:   e.Value.flags,
+	})
+	if err != nil {
+		slog.Info("spill to disk failed", "group", g.name, "key", e.Key, "err", err)
+		return
+	}
+	g.stats.diskWrites.Add(1)
+}
+
+// getFromDisk moves key from the disk tier back to the cache
+func (g *Group) getFromDisk(ctx context.Context, key string) (ByteView, bool) {
+	d := g.disk.Load()
+	if d == nil {
+		return ByteView{}, false
+	}
+	_, span := tracing.Start(ctx, "go_cache.disk")
+	defer span.End()
+
+	e, ok, err := d.Take(key)
+	span.SetAttr("hit", strconv.FormatBool(ok))
+	if err != nil {
+		span.SetError(err)
+		slog.Info("read from disk failed", "group", g.name, "key", key, "err", err)
+		return ByteView{}, false
+	}
+	if !ok {
+		return ByteView{}, false
+	}
+	// the key was not cached, a value written since is newer
+	value := ByteView{b: e.Value, expire: e.Expire, version: e.Version, flags: e.Flags}
+	return g.populateCache(key, value, 0), true
+}
+
+// removeFromDisk drops key from the disk tier, which the cache events
+// miss when the key is not cached in memory.
+func (g *Group) removeFromDisk(key string) bool {
+	if d := g.disk.Load(); d != nil {
+		return d.Delete(key)
+	}
+	return false
+}
+
+func (g *Group) clearDisk() {
+	if d := g.disk.Load(); d != nil {
+		d.Clear()
+	}
+}
+
+func (g *Group) diskStats() diskcache.Stats {
+	if d := g.disk.Load(); d != nil {
+		return d.Stats()
+	}
+	return diskcache.Stats{}
+}
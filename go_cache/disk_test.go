@@ -0,0 +1,57 @@
+package go_cache_test
+
+import (
+	"go_cache"
+	"go_cache/diskcache"
+	"testing"
+)
+
+func TestDiskCache(t *testing.T) {
+	loads := make(map[string]int)
+	g := go_cache.NewGroup("disk", 6, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			loads[key]++
+			return []byte("v" + key[1:]), nil
+		}))
+	store, err := diskcache.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	g.SetDiskCache(store)
+
+	v1, _ := g.Get("k1")
+	g.Get("k2") // 6 bytes hold one entry, k1 is spilled
+	if info := g.Info(); info.Items != 1 || info.DiskItems != 1 {
+		t.Fatalf("info = %+v, want k1 on disk", info)
+	}
+
+	v, err := g.Get("k1") // moved back, k2 spilled
+	if err != nil || v.String() != "v1" || v.Version() != v1.Version() || loads["k1"] != 1 {
+		t.Fatalf("Get(k1) = %s@%d, %v with %d loads, want v1@%d from disk",
+			v, v.Version(), err, loads["k1"], v1.Version())
+	}
+	if s := g.Stats(); s.DiskHits != 1 || s.DiskWrites != 2 || s.Loads != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// writes compare with the version on disk
+	v2, _ := g.Get("k2")
+	if _, err := g.CompareAndSet("k1", v1.Version(), []byte("new")); err != nil {
+		t.Fatalf("CompareAndSet of a spilled value: %v", err)
+	}
+
+	// removing a spilled value drops it from disk
+	if !g.Remove("k2") {
+		t.Fatal("expect Remove to report the value on disk")
+	}
+	if v, _ := g.Get("k2"); loads["k2"] != 2 || v.Version() == v2.Version() {
+		t.Fatalf("Get(k2) after Remove = %s with %d loads, want a reload", v, loads["k2"])
+	}
+	if info := g.Info(); info.DiskItems != 1 {
+		t.Fatalf("info = %+v, want the CAS value on disk", info)
+	}
+	if v, _ := g.Get("k1"); v.String() != "new" {
+		t.Fatalf("Get(k1) = %s, want the CAS value", v)
+	}
+}
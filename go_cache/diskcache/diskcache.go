@@ -0,0 +1,384 @@
+// Package diskcache is a cache tier on local disk. Entries are appended to
+// a log file and located by an in-memory index. Overwritten and deleted
+// entries leave garbage in the log, reclaimed by compaction.
+//
+// The log is recreated by Open: a restarted node may have missed
+// invalidations while it was down, so nothing is trusted across restarts.
+package diskcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	logName = "cache.log"
+	tmpName = "cache.log.tmp"
+
+	// header is crc32 | key length | value length | flags | version | expire in unix nanoseconds
+	headerLen = 4 + 4 + 4 + 4 + 8 + 8
+
+	// compaction waits for this much garbage, and for more garbage than live bytes
+	minCompactGarbage = 1 << 20
+)
+
+// ErrCorrupt is returned when a record read back does not match its checksum
+var ErrCorrupt = errors.New("diskcache: corrupt record")
+
+// Entry is a cached value with its metadata
+type Entry struct {
+	Value   []byte
+	Expire  time.Time // zero means never expire
+	Version uint64
+	Flags   uint32
+}
+
+// Stats describes the usage of a Store
+type Stats struct {
+	Items       int
+	Bytes       int64 // of live records
+	FileBytes   int64 // of the log, including garbage
+	Evictions   int64 // entries dropped to keep within the byte budget
+	Compactions int64
+}
+
+// Store is a disk cache with a byte budget, safe for concurrent access.
+type Store struct {
+	mu       sync.RWMutex
+	path     string // of the log, kept by compactions
+	tmpPath  string // of the log being compacted
+	f        *os.File
+	size     int64 // end of the log
+	maxBytes int64 // budget of live records, 0 means no limit
+
+	index   map[string]item
+	queue   []queued // records in log order, the oldest first. May refer to garbage.
+	live    int64
+	garbage int64
+
+	evictions   int64
+	compactions int64
+}
+
+// item locates the live record of a key
+type item struct {
+	off    int64
+	size   int64
+	expire time.Time
+}
+
+type queued struct {
+	key string
+	off int64
+}
+
+// Open creates the log in dir, dropping any previous one. maxBytes bounds
+// the bytes of live records, the oldest are evicted beyond it.
+func Open(dir string, maxBytes int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, logName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		path:     path,
+		tmpPath:  filepath.Join(dir, tmpName),
+		f:        f,
+		maxBytes: maxBytes,
+		index:    make(map[string]item),
+	}, nil
+}
+
+// Close closes and removes the log
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	os.Remove(s.path)
+	s.f = nil
+	s.index = nil
+	s.queue = nil
+	return err
+}
+
+// Put stores e for key, replacing the previous entry. Entries larger than
+// the budget are not stored.
+func (s *Store) Put(key string, e Entry) error {
+	rec := encode(key, e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	s.deleteLocked(key)
+	if s.maxBytes > 0 && int64(len(rec)) > s.maxBytes {
+		return nil
+	}
+
+	if _, err := s.f.WriteAt(rec, s.size); err != nil {
+		return err
+	}
+	s.index[key] = item{off: s.size, size: int64(len(rec)), expire: e.Expire}
+	s.queue = append(s.queue, queued{key, s.size})
+	s.size += int64(len(rec))
+	s.live += int64(len(rec))
+
+	for s.maxBytes > 0 && s.live > s.maxBytes {
+		s.evictOldest()
+	}
+	return s.maybeCompact()
+}
+
+// Get returns the entry of key. Expired entries are not returned.
+func (s *Store) Get(key string) (Entry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getLocked(key)
+}
+
+// Take returns the entry of key and deletes it, e.g. to move it to a
+// faster tier.
+func (s *Store) Take(key string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok, err := s.getLocked(key)
+	s.deleteLocked(key)
+	if err != nil || !ok {
+		return Entry{}, false, err
+	}
+	return e, true, s.maybeCompact()
+}
+
+func (s *Store) getLocked(key string) (Entry, bool, error) {
+	if s.f == nil {
+		return Entry{}, false, os.ErrClosed
+	}
+	it, ok := s.index[key]
+	if !ok || (!it.expire.IsZero() && time.Now().After(it.expire)) {
+		return Entry{}, false, nil
+	}
+
+	rec := make([]byte, it.size)
+	if _, err := s.f.ReadAt(rec, it.off); err != nil {
+		return Entry{}, false, err
+	}
+	k, e, err := decode(rec)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if k != key {
+		return Entry{}, false, ErrCorrupt
+	}
+	return e, true, nil
+}
+
+// Keys returns the keys of the entries in no particular order
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Delete drops the entry of key, reporting whether there was one
+func (s *Store) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.deleteLocked(key) {
+		return false
+	}
+	s.maybeCompact()
+	return true
+}
+
+func (s *Store) deleteLocked(key string) bool {
+	it, ok := s.index[key]
+	if !ok {
+		return false
+	}
+	delete(s.index, key)
+	s.live -= it.size
+	s.garbage += it.size
+	return true
+}
+
+// Clear drops all entries and truncates the log
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	s.index = make(map[string]item)
+	s.queue = nil
+	s.size, s.live, s.garbage = 0, 0, 0
+	return s.f.Truncate(0)
+}
+
+// evictOldest drops the live record written first
+func (s *Store) evictOldest() {
+	for len(s.queue) > 0 {
+		q := s.queue[0]
+		s.queue = s.queue[1:]
+		if it, ok := s.index[q.key]; ok && it.off == q.off {
+			s.deleteLocked(q.key)
+			s.evictions++
+			return
+		}
+	}
+}
+
+func (s *Store) maybeCompact() error {
+	if s.garbage < minCompactGarbage || s.garbage < s.live {
+		return nil
+	}
+	return s.compactLocked()
+}
+
+// Compact rewrites the live records to a new log, reclaiming the garbage.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	return s.compactLocked()
+}
+
+func (s *Store) compactLocked() error {
+	tmp, err := os.OpenFile(s.tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	index := make(map[string]item, len(s.index))
+	queue := make([]queued, 0, len(s.index))
+	var size int64
+	var buf []byte
+	for _, q := range s.queue {
+		it, ok := s.index[q.key]
+		if !ok || it.off != q.off || (!it.expire.IsZero() && now.After(it.expire)) {
+			continue
+		}
+		if int64(cap(buf)) < it.size {
+			buf = make([]byte, it.size)
+		}
+		rec := buf[:it.size]
+		if _, err = s.f.ReadAt(rec, it.off); err == nil {
+			_, err = tmp.WriteAt(rec, size)
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(s.tmpPath)
+			return err
+		}
+		index[q.key] = item{off: size, size: it.size, expire: it.expire}
+		queue = append(queue, queued{q.key, size})
+		size += it.size
+	}
+
+	// the open tmp file becomes the log, named as the log
+	if err := os.Rename(s.tmpPath, s.path); err != nil {
+		tmp.Close()
+		os.Remove(s.tmpPath)
+		return err
+	}
+	s.f.Close()
+	s.f = tmp
+	s.index = index
+	s.queue = queue
+	s.size = size
+	s.live = size
+	s.garbage = 0
+	s.compactions++
+	return nil
+}
+
+// SetMaxBytes changes the budget, evicting the oldest entries beyond it
+func (s *Store) SetMaxBytes(maxBytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxBytes = maxBytes
+	for s.maxBytes > 0 && s.live > s.maxBytes {
+		s.evictOldest()
+	}
+	s.maybeCompact()
+}
+
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Stats{
+		Items:       len(s.index),
+		Bytes:       s.live,
+		FileBytes:   s.size,
+		Evictions:   s.evictions,
+		Compactions: s.compactions,
+	}
+}
+
+func encode(key string, e Entry) []byte {
+	var expire int64
+	if !e.Expire.IsZero() {
+		expire = e.Expire.UnixNano()
+	}
+	rec := make([]byte, headerLen, headerLen+len(key)+len(e.Value))
+	binary.BigEndian.PutUint32(rec[4:], uint32(len(key)))
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(e.Value)))
+	binary.BigEndian.PutUint32(rec[12:], e.Flags)
+	binary.BigEndian.PutUint64(rec[16:], e.Version)
+	binary.BigEndian.PutUint64(rec[24:], uint64(expire))
+	rec = append(rec, key...)
+	rec = append(rec, e.Value...)
+	binary.BigEndian.PutUint32(rec[0:], crc32.ChecksumIEEE(rec[4:]))
+	return rec
+}
+
+func decode(rec []byte) (string, Entry, error) {
+	if len(rec) < headerLen {
+		return "", Entry{}, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(rec[4:]) != binary.BigEndian.Uint32(rec[0:]) {
+		return "", Entry{}, ErrCorrupt
+	}
+	keyLen := int(binary.BigEndian.Uint32(rec[4:]))
+	valueLen := int(binary.BigEndian.Uint32(rec[8:]))
+	if headerLen+keyLen+valueLen != len(rec) {
+		return "", Entry{}, ErrCorrupt
+	}
+	e := Entry{
+		Flags:   binary.BigEndian.Uint32(rec[12:]),
+		Version: binary.BigEndian.Uint64(rec[16:]),
+	}
+	if expire := int64(binary.BigEndian.Uint64(rec[24:])); expire != 0 {
+		e.Expire = time.Unix(0, expire)
+	}
+	key := string(rec[headerLen : headerLen+keyLen])
+	e.Value = rec[headerLen+keyLen:]
+	return key, e, nil
+}
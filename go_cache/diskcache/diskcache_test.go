@@ -0,0 +1,218 @@
+package diskcache_test
+
+import (
+	"bytes"
+	"fmt"
+	"go_cache/diskcache"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func open(t *testing.T, maxBytes int64) *diskcache.Store {
+	t.Helper()
+	s, err := diskcache.Open(t.TempDir(), maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPutGet(t *testing.T) {
+	s := open(t, 0)
+	expire := time.Now().Add(time.Hour).Round(0)
+	want := diskcache.Entry{Value: []byte("630"), Expire: expire, Version: 7, Flags: 3}
+	if err := s.Put("Tom", want); err != nil {
+		t.Fatal(err)
+	}
+	s.Put("Jack", diskcache.Entry{Value: []byte("589")})
+
+	got, ok, err := s.Get("Tom")
+	if err != nil || !ok {
+		t.Fatalf("Get(Tom) = %v, %v", ok, err)
+	}
+	if !bytes.Equal(got.Value, want.Value) || !got.Expire.Equal(expire) ||
+		got.Version != want.Version || got.Flags != want.Flags {
+		t.Fatalf("Get(Tom) = %+v, want %+v", got, want)
+	}
+
+	s.Put("Tom", diskcache.Entry{Value: []byte("631")})
+	if got, _, _ := s.Get("Tom"); string(got.Value) != "631" || !got.Expire.IsZero() {
+		t.Fatalf("Get(Tom) after overwrite = %+v", got)
+	}
+
+	if e, ok, _ := s.Take("Jack"); !ok || string(e.Value) != "589" {
+		t.Fatalf("Take(Jack) = %s, %v", e.Value, ok)
+	}
+	if _, ok, _ := s.Get("Jack"); ok {
+		t.Fatal("expect Jack deleted by Take")
+	}
+	if !s.Delete("Tom") || s.Delete("Tom") {
+		t.Fatal("expect Delete to report the entry once")
+	}
+	if st := s.Stats(); st.Items != 0 || st.Bytes != 0 {
+		t.Fatalf("stats after deletes = %+v", st)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s := open(t, 0)
+	s.Put("k", diskcache.Entry{Value: []byte("v"), Expire: time.Now().Add(time.Millisecond)})
+	time.Sleep(2 * time.Millisecond)
+	if _, ok, _ := s.Get("k"); ok {
+		t.Fatal("expect expired entry to miss")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	s := open(t, 0)
+	s.Put("k0", diskcache.Entry{Value: []byte("v")})
+	size := s.Stats().Bytes // of one record
+	s.Close()
+
+	s = open(t, 3*size)
+	for i := 0; i < 5; i++ {
+		s.Put(fmt.Sprintf("k%d", i), diskcache.Entry{Value: []byte("v")})
+	}
+	st := s.Stats()
+	if st.Items != 3 || st.Bytes != 3*size || st.Evictions != 2 {
+		t.Fatalf("stats = %+v, want 3 items of %d bytes", st, size)
+	}
+	for i, want := range []bool{false, false, true, true, true} {
+		if _, ok, _ := s.Get(fmt.Sprintf("k%d", i)); ok != want {
+			t.Errorf("k%d cached = %v, want %v", i, ok, want)
+		}
+	}
+
+	s.Put("big", diskcache.Entry{Value: make([]byte, 3*size)})
+	if _, ok, _ := s.Get("big"); ok || s.Stats().Items != 3 {
+		t.Fatal("expect an entry over the budget not to be stored")
+	}
+
+	s.SetMaxBytes(size)
+	if _, ok, _ := s.Get("k4"); !ok || s.Stats().Items != 1 {
+		t.Fatalf("stats after shrinking = %+v, want the newest entry", s.Stats())
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	s, err := diskcache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	value := make([]byte, 64<<10)
+	for i := 0; i < 8; i++ {
+		s.Put(fmt.Sprintf("k%d", i), diskcache.Entry{Value: value})
+	}
+	for i := 0; i < 6; i++ {
+		s.Delete(fmt.Sprintf("k%d", i))
+	}
+	st := s.Stats()
+	if st.FileBytes <= st.Bytes || st.Compactions != 0 {
+		t.Fatalf("stats before compaction = %+v", st)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	st = s.Stats()
+	if st.FileBytes != st.Bytes || st.Items != 2 || st.Compactions != 1 {
+		t.Fatalf("stats after compaction = %+v", st)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "cache.log")); err != nil || fi.Size() != st.FileBytes {
+		t.Fatalf("log size = %v, %v, want %d", fi.Size(), err, st.FileBytes)
+	}
+	for _, key := range []string{"k6", "k7"} {
+		if e, ok, err := s.Get(key); !ok || err != nil || len(e.Value) != len(value) {
+			t.Fatalf("Get(%s) after compaction = %v, %v", key, ok, err)
+		}
+	}
+
+	// overwrites leave garbage until compaction runs by itself
+	for i := 0; i < 40; i++ {
+		s.Put("k6", diskcache.Entry{Value: value})
+	}
+	if st := s.Stats(); st.Compactions < 2 || st.FileBytes > 2<<20 {
+		t.Fatalf("stats after overwrites = %+v", st)
+	}
+}
+
+func TestCompactRepeatedly(t *testing.T) {
+	dir := t.TempDir()
+	s, err := diskcache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 4; i++ {
+			s.Put(fmt.Sprintf("k%d", i), diskcache.Entry{Value: []byte(fmt.Sprintf("v%d-%d", i, round))})
+		}
+		if err := s.Compact(); err != nil {
+			t.Fatalf("round %d: Compact: %v", round, err)
+		}
+		for i := 0; i < 4; i++ {
+			want := fmt.Sprintf("v%d-%d", i, round)
+			if e, ok, err := s.Get(fmt.Sprintf("k%d", i)); !ok || err != nil || string(e.Value) != want {
+				t.Fatalf("round %d: Get(k%d) = %q, %v, %v, want %s", round, i, e.Value, ok, err, want)
+			}
+		}
+		// the log keeps its name, nothing is left aside
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 || entries[0].Name() != "cache.log" {
+			t.Fatalf("round %d: files %v, want cache.log only", round, entries)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("files %v left after Close", entries)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	s, err := diskcache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Put("k", diskcache.Entry{Value: []byte("value")})
+	f, err := os.OpenFile(filepath.Join(dir, "cache.log"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := s.Stats()
+	f.WriteAt([]byte("X"), st.FileBytes-1)
+	f.Close()
+
+	if _, ok, err := s.Get("k"); ok || err != diskcache.ErrCorrupt {
+		t.Fatalf("Get of a corrupt record = %v, %v", ok, err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	s, err := diskcache.Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("k", diskcache.Entry{Value: []byte("v")})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache.log")); !os.IsNotExist(err) {
+		t.Fatalf("expect the log removed, got %v", err)
+	}
+	if err := s.Put("k", diskcache.Entry{}); err != os.ErrClosed {
+		t.Fatalf("Put after Close = %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package go_cache
+
+import "sync"
+
+// EventReason tells why an Event was emitted
+type EventReason int
+
+const (
+	EventInsert EventReason = iota // value added or replaced
+	EventEvict                     // value purged to keep the cache within its bytes
+	EventExpire                    // value dropped because its TTL passed
+	EventRemove                    // value removed explicitly
+)
+
+func (r EventReason) String() string {
+	switch r {
+	case EventInsert:
+		return "insert"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event describes a change of a group's cache.
+// e.g. write evicted values behind to a slower store by subscribing EventEvict.
+type Event struct {
+	Group  string
+	Key    string
+	Value  ByteView
+	Reason EventReason
+}
+
+type subscriber struct {
+	fn func(Event)
+}
+
+// event subscribers of a Group
+type subscribers struct {
+	mu   sync.RWMutex
+	list []*subscriber
+}
+
+// Subscribe calls fn for every event of the group until unsubscribe is called.
+// fn runs synchronously in the goroutine changing the cache, without holding
+// the cache lock, so it should be fast and hand slow work to other goroutines.
+// fn must not subscribe or unsubscribe itself.
+func (g *Group) Subscribe(fn func(Event)) (unsubscribe func()) {
+	s := &subscriber{fn: fn}
+
+	g.subs.mu.Lock()
+	g.subs.list = append(g.subs.list, s)
+	g.subs.mu.Unlock()
+	g.mainCache.setOnEvent(g.dispatch)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.subs.mu.Lock()
+			defer g.subs.mu.Unlock()
+			for i, v := range g.subs.list {
+				if v == s {
+					g.subs.list = append(g.subs.list[:i:i], g.subs.list[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// SubscribeChan delivers the group's events to a channel with a buffer of size.
+// Events are dropped rather than blocking the cache when the buffer is full.
+// The channel is closed by unsubscribe.
+func (g *Group) SubscribeChan(size int) (events <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, size)
+	cancel := g.Subscribe(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			// after cancel returns no sender holds the subscribers lock
+			cancel()
+			close(ch)
+		})
+	}
+}
+
+func (g *Group) dispatch(key string, value ByteView, reason EventReason) {
+	g.subs.mu.RLock()
+	defer g.subs.mu.RUnlock()
+
+	if len(g.subs.list) == 0 {
+		return
+	}
+	e := Event{Group: g.name, Key: key, Value: value, Reason: reason}
+	for _, s := range g.subs.list {
+		s.fn(e)
+	}
+}
@@ -0,0 +1,64 @@
+package go_cache_test
+
+import (
+	"go_cache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	g := go_cache.NewGroup("events", 10, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("value"), nil
+		}))
+
+	var got []string
+	unsubscribe := g.Subscribe(func(e go_cache.Event) {
+		got = append(got, e.Reason.String()+":"+e.Key)
+	})
+
+	g.Get("k1") // insert k1 (7 bytes)
+	g.Get("k2") // insert k2, evict k1
+	g.Remove("k2")
+	g.SetTTL(time.Millisecond)
+	g.Get("k3")
+	time.Sleep(2 * time.Millisecond)
+	g.Get("k3") // expire k3, then load it again
+
+	want := []string{
+		"insert:k1",
+		"insert:k2", "evict:k1",
+		"remove:k2",
+		"insert:k3",
+		"expire:k3", "insert:k3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	unsubscribe()
+	g.Remove("k3")
+	if len(got) != len(want) {
+		t.Fatalf("event delivered after unsubscribe: %v", got[len(want):])
+	}
+}
+
+func TestSubscribeChan(t *testing.T) {
+	g := go_cache.NewGroup("events-chan", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	events, unsubscribe := g.SubscribeChan(1)
+	g.Get("k1")
+	g.Get("k2") // dropped, buffer is full
+
+	if e := <-events; e.Group != "events-chan" || e.Key != "k1" || e.Reason != go_cache.EventInsert || e.Value.String() != "k1" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Fatal("channel should be closed after unsubscribe")
+	}
+}
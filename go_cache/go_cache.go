@@ -1,9 +1,17 @@
 package go_cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go_cache/diskcache"
+	"go_cache/singleflight"
+	"go_cache/tracing"
 	"log/slog"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 //                             是
@@ -20,88 +28,457 @@ import (
 //                     |  否                                    ↓  否
 //                     |----------------------------> 回退到本地节点处理。
 
+// source of value versions on this node. Seeded by clock so that
+// versions keep growing across restarts.
+var lastVersion atomic.Uint64
+
+func init() {
+	lastVersion.Store(uint64(time.Now().UnixNano()))
+}
+
+func nextVersion() uint64 {
+	return lastVersion.Add(1)
+}
+
 var (
-	// protect groups map
-	mu     sync.RWMutex
-	groups = make(map[string]*Group)
+	// ErrVersionMismatch is returned by CompareAndSet when the cached value has changed.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrGroupExists is returned by CreateGroup when the name is taken.
+	ErrGroupExists = errors.New("group already exists")
+	// ErrGroupDeleted is returned by operations on a group removed by DeleteGroup.
+	ErrGroupDeleted = errors.New("group deleted")
+	// ErrNotFound can be wrapped by Getters to tell a missing key from a failure.
+	ErrNotFound = errors.New("not found")
+	// ErrOverloaded is returned when a node sheds a request to protect itself
+	// or its Getter. Callers should fall back or retry later.
+	ErrOverloaded = errors.New("overloaded")
 )
 
 // Group is a cache namespace and associated data loaded spread over.
 type Group struct {
-	name      string // namespace's name
-	getter    Getter // callback when miss data
-	mainCache cache
+	name       string // namespace's name
+	getter     Getter // callback when miss data
+	mainCache  cache
+	cacheBytes atomic.Int64 // budget set by SetCacheBytes, a Governor may hold mainCache below it
+	ttl        atomic.Int64 // time.Duration of cached values, 0 means never expire
+
+	// time.Duration before expiry in which a hit triggers a background reload
+	refreshAhead atomic.Int64
+	// time.Duration after expiry in which a value is served if reloading fails
+	staleIfError atomic.Int64
+
+	loader     singleflight.Group // make sure each key is only fetched once at the same time
+	refreshing sync.Map           // keys being refreshed in background
+	maxLoads   atomic.Int64       // bound of concurrent loads from getter, 0 means unbounded
+	loading    atomic.Int64       // concurrent loads from getter
+	leases     leases             // of the keys owned by this node, granted to peers
+	leaseTTL   atomic.Int64       // time.Duration of the leases, 0 means defaultLeaseTTL
 
 	peers PeerPicker // get value from peer cache
+
+	hot      hotKeys // gets counted by key
+	hotCache cache   // hot keys owned by peers, see SetHotKeys
+
+	disk      atomic.Pointer[diskcache.Store] // optional tier below mainCache
+	spillOnce sync.Once
+
+	subs subscribers // observe mainCache events
+
+	stats   groupStats
+	deleted atomic.Bool
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+// SetCacheBytes changes the byte budget, evicting values while it is exceeded.
+// 0 means no limit.
+func (g *Group) SetCacheBytes(cacheBytes int64) {
+	g.cacheBytes.Store(cacheBytes)
+	g.mainCache.setCacheBytes(cacheBytes)
+}
+
+// CacheBytes returns the byte budget.
+func (g *Group) CacheBytes() int64 {
+	_, _, cacheBytes := g.mainCache.stats()
+	return cacheBytes
+}
+
+// TTL returns how long loaded values stay in the cache. 0 means forever.
+func (g *Group) TTL() time.Duration {
+	return time.Duration(g.ttl.Load())
+}
+
+// SetTTL sets how long loaded values stay in the cache. 0 means forever.
+// It applies to values loaded afterwards.
+func (g *Group) SetTTL(ttl time.Duration) {
+	g.ttl.Store(int64(ttl))
+}
+
+// SetRefreshAhead makes a hit on a value expiring within window serve it
+// and reload it in background, so popular keys never miss on expiry.
+// 0 disables it.
+func (g *Group) SetRefreshAhead(window time.Duration) {
+	g.refreshAhead.Store(int64(window))
+}
+
+// SetStaleIfError keeps expired values for maxStale and serves them
+// when reloading fails. 0 disables it.
+func (g *Group) SetStaleIfError(maxStale time.Duration) {
+	g.staleIfError.Store(int64(maxStale))
+}
+
+// Remove deletes key from this node's cache only.
+func (g *Group) Remove(key string) bool {
+	g.leases.revoke(key)
+	removed := g.mainCache.remove(key)
+	removed = g.hotCache.remove(key) || removed
+	return g.removeFromDisk(key) || removed
+}
+
+// Invalidate drops key from the caches of this node and, when the registered
+// peers support it, of every other node.
+func (g *Group) Invalidate(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	g.Remove(key)
+
+	if inv, ok := g.peers.(PeerInvalidator); ok {
+		return inv.Invalidate(g.name, key)
+	}
+	return nil
+}
+
+// CompareAndSet stores value for key if its current version is expectedVersion,
+// where 0 means the key must not be cached. It runs on the node owning the key
+// and returns the stored value with its new version. The value lives in the
+// cache only, the Getter's source is not written.
+func (g *Group) CompareAndSet(key string, expectedVersion uint64, value []byte) (ByteView, error) {
+	return g.Store(key, &SetRequest{Value: value, CheckVersion: true, Version: expectedVersion})
+}
+
+// Store executes req on the node owning key and returns the stored value.
+// Set, SetWithTTL and CompareAndSet are shortcuts of it.
+func (g *Group) Store(key string, req *SetRequest) (ByteView, error) {
+	if err := checkKey(key); err != nil {
+		return ByteView{}, err
+	}
+	if g.deleted.Load() {
+		return ByteView{}, ErrGroupDeleted
+	}
+
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			// the owner must decide, falling back locally would fork the versions
+			setter, ok := peer.(PeerSetter)
+			if !ok {
+				return ByteView{}, fmt.Errorf("peer owning %s does not accept writes", key)
+			}
+			// this node must read its own write
+			g.hotCache.remove(key)
+			return setter.Set(g.name, key, req)
+		}
+	}
+
+	return g.setLocally(key, req)
+}
+
+// setLocally executes a write on the owner of key
+func (g *Group) setLocally(key string, req *SetRequest) (ByteView, error) {
+	value := g.newEntry(cloneBytes(req.Value), req.TTL)
+	value.flags = req.Flags
+	if !req.CheckVersion {
+		g.mainCache.add(key, value)
+		g.leases.revoke(key)
+		return value, nil
+	}
+	// the value to compare with may have been spilled to disk
+	g.getFromDisk(context.Background(), key)
+	if !g.mainCache.compareAndAdd(key, value, req.Version) {
+		return ByteView{}, ErrVersionMismatch
+	}
+	// a value loaded under the lease would overwrite the write
+	g.leases.revoke(key)
+	return value, nil
+}
+
+// Set stores value for key on the node owning it, regardless of its current
+// version. The value lives in the cache only, the Getter's source is not written.
+func (g *Group) Set(key string, value []byte) (ByteView, error) {
+	return g.Store(key, &SetRequest{Value: value})
+}
+
+// SetWithTTL is like Set but the value expires after ttl instead of the group's TTL.
+func (g *Group) SetWithTTL(key string, value []byte, ttl time.Duration) (ByteView, error) {
+	return g.Store(key, &SetRequest{Value: value, TTL: ttl})
+}
+
+// Expire makes the cached value of key expire after ttl, or never if ttl <= 0.
+// It runs on the node owning the key and reports whether the key was cached.
+func (g *Group) Expire(key string, ttl time.Duration) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	if g.deleted.Load() {
+		return false, ErrGroupDeleted
+	}
+
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			setter, ok := peer.(PeerSetter)
+			if !ok {
+				return false, fmt.Errorf("peer owning %s does not accept writes", key)
+			}
+			g.hotCache.remove(key)
+			return setter.Touch(g.name, key, ttl)
+		}
+	}
+	return g.expireLocally(key, ttl), nil
 }
 
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
-	if getter == nil {
-		panic("nil Getter")
+func (g *Group) expireLocally(key string, ttl time.Duration) bool {
+	var expire time.Time
+	if ttl > 0 {
+		expire = time.Now().Add(ttl)
 	}
-	g := &Group{
-		name:      name,
-		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
+	g.getFromDisk(context.Background(), key)
+	return g.mainCache.touch(key, expire)
+}
+
+// Peek returns the value of key cached by the node owning it, without
+// loading it on a miss.
+func (g *Group) Peek(key string) (ByteView, bool, error) {
+	if err := checkKey(key); err != nil {
+		return ByteView{}, false, err
+	}
+	if g.deleted.Load() {
+		return ByteView{}, false, ErrGroupDeleted
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	groups[name] = g
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			peeker, ok := peer.(PeerPeeker)
+			if !ok {
+				return ByteView{}, false, fmt.Errorf("peer owning %s does not support peeks", key)
+			}
+			return peeker.Peek(g.name, key)
+		}
+	}
+	v, ok := g.peekLocally(key)
+	return v, ok, nil
+}
 
-	return g
+func (g *Group) peekLocally(key string) (ByteView, bool) {
+	if v, ok := g.mainCache.peek(key); ok {
+		return v, true
+	}
+	return g.getFromDisk(context.Background(), key)
 }
 
-func GetGroup(name string) *Group {
-	mu.RLock()
-	defer mu.RUnlock()
-	return groups[name]
+// Delete drops key from the caches like Invalidate, reporting whether the
+// node owning it had it cached. A key whose owner cannot be asked is
+// reported deleted.
+func (g *Group) Delete(key string) (bool, error) {
+	_, cached, err := g.Peek(key)
+	if err != nil {
+		cached = true
+	}
+	if err := g.Invalidate(key); err != nil {
+		return false, err
+	}
+	return cached, nil
+}
+
+// purge drops all values cached by this node
+func (g *Group) purge() {
+	g.mainCache.clear()
+	g.hotCache.clear()
+	g.clearDisk()
 }
 
 // Get value for a key from main cache
 func (g *Group) Get(key string) (ByteView, error) {
-	if key == "" {
-		return ByteView{}, fmt.Errorf("key is required")
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, tracing the stages of the lookup as children of
+// the span in ctx.
+func (g *Group) GetContext(ctx context.Context, key string) (value ByteView, err error) {
+	if err := checkKey(key); err != nil {
+		return ByteView{}, err
 	}
+	if g.deleted.Load() {
+		return ByteView{}, ErrGroupDeleted
+	}
+
+	ctx, span := tracing.Start(ctx, "go_cache.Get")
+	span.SetAttr("group", g.name)
+	span.SetAttr("key", key)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
 
-	if v, ok := g.mainCache.get(key); ok {
+	g.stats.gets.Add(1)
+	g.hot.record(key)
+	_, cacheSpan := tracing.Start(ctx, "go_cache.cache")
+	v, ok := g.mainCache.get(key, time.Duration(g.staleIfError.Load()))
+	cacheSpan.SetAttr("hit", strconv.FormatBool(ok))
+	cacheSpan.End()
+	if !ok {
+		if v, ok = g.hotCache.get(key, 0); ok {
+			g.stats.hotHits.Add(1)
+			return v, nil
+		}
+		if v, ok = g.getFromDisk(ctx, key); ok {
+			g.stats.diskHits.Add(1)
+			return v, nil
+		}
+		g.stats.loads.Add(1)
+		return g.load(ctx, key)
+	}
+
+	now := time.Now()
+	if !v.expired(now) {
+		g.stats.cacheHits.Add(1)
 		slog.Info(fmt.Sprintf("cache hit: %s", key))
+		if window := time.Duration(g.refreshAhead.Load()); window > 0 &&
+			!v.expire.IsZero() && v.expire.Sub(now) < window {
+			g.refresh(key)
+		}
 		return v, nil
 	}
 
-	return g.load(key)
+	// stale value kept for stale-if-error
+	g.stats.loads.Add(1)
+	value, err = g.load(ctx, key)
+	if err != nil {
+		g.stats.staleHits.Add(1)
+		slog.Info("serve stale value", "key", key, "err", err)
+		span.SetAttr("stale", "true")
+		return v, nil
+	}
+	return value, nil
 }
 
-func (g *Group) load(key string) (ByteView, error) {
-	if g.peers != nil {
-		if peer, ok := g.peers.PickPeer(key); ok {
-			if value, err := g.getFromPeer(peer, key); err == nil {
-				return value, nil
-			} else {
-				slog.Info("[GeeCache] Failed to get from peer", "peer", err)
+// refresh reloads key in background, at most once at a time
+func (g *Group) refresh(key string) {
+	if _, loaded := g.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer g.refreshing.Delete(key)
+		if _, err := g.load(context.Background(), key); err != nil {
+			slog.Info("refresh ahead failed", "key", key, "err", err)
+		}
+	}()
+}
+
+// load fetches key from its owner or the Getter. Concurrent loads of a key
+// share the work, traced under the span of the first caller.
+func (g *Group) load(ctx context.Context, key string) (ByteView, error) {
+	ctx, span := tracing.Start(ctx, "go_cache.load")
+	defer span.End()
+
+	// the load is shared by the callers coalesced, none of them cancels it
+	// but each stops waiting when its own ctx is done
+	loadCtx := context.WithoutCancel(ctx)
+	ch := g.loader.DoChan(key, func() (interface{}, error) {
+		g.stats.loadsDeduped.Add(1)
+		if g.peers != nil {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				if value, err := g.getFromPeer(loadCtx, peer, key); err == nil {
+					g.stats.peerLoads.Add(1)
+					g.replicate(key, value)
+					return value, nil
+				} else if errors.Is(err, ErrOverloaded) {
+					// the owner sheds load, not an error of the peer
+					g.stats.peerRejects.Add(1)
+					if value, ok, err := g.loadWithLease(loadCtx, peer, key); ok {
+						return value, err
+					}
+					slog.Info("[GeeCache] Peer overloaded, load locally", "peer", err)
+				} else {
+					g.stats.peerErrors.Add(1)
+					slog.Info("[GeeCache] Failed to get from peer", "peer", err)
+				}
 			}
 		}
-	}
 
-	return g.getLocally(key)
+		value, err := g.getLocally(loadCtx, key)
+		if err != nil {
+			g.stats.localLoadErrs.Add(1)
+			return nil, err
+		}
+		g.stats.localLoads.Add(1)
+		return value, nil
+	})
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		span.SetError(ctx.Err())
+		return ByteView{}, ctx.Err()
+	}
+	span.SetAttr("shared", strconv.FormatBool(res.Shared))
+	if res.Err != nil {
+		span.SetError(res.Err)
+		return ByteView{}, res.Err
+	}
+	return res.Val.(ByteView), nil
 }
 
 // ************************** get value in local other source
 
 // use in single machie
-func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	seen := g.mainCache.version(key)
+	bytes, err := g.callGetter(ctx, key)
 	if err != nil {
 		return ByteView{}, err
 	}
-	value := ByteView{b: bytes}
-	g.populateCache(key, value)
-	return value, nil
+	return g.populateCache(key, g.newEntry(bytes, 0), seen), nil
 }
 
-// use in single machine
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+// callGetter loads key from the Getter within the limit of concurrent loads
+func (g *Group) callGetter(ctx context.Context, key string) ([]byte, error) {
+	_, span := tracing.Start(ctx, "go_cache.getter")
+	defer span.End()
+
+	release, err := g.acquireLoad()
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	start := time.Now()
+	bytes, err := g.getter.Get(key)
+	g.stats.loadLatency.since(start)
+	release()
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	return bytes, nil
+}
+
+// newEntry versions b and stamps it with ttl, or the group's TTL if ttl is 0
+func (g *Group) newEntry(b []byte, ttl time.Duration) ByteView {
+	value := ByteView{b: b, version: nextVersion()}
+	if ttl <= 0 {
+		ttl = time.Duration(g.ttl.Load())
+	}
+	if ttl > 0 {
+		value.expire = time.Now().Add(ttl)
+	}
+	return value
+}
+
+// populateCache caches value loaded while version was cached for key,
+// unless a write replaced it meanwhile. It returns the value cached.
+func (g *Group) populateCache(key string, value ByteView, version uint64) ByteView {
+	return g.mainCache.fill(key, value, version)
 }
 
 // ************************** get value in peer's cache
@@ -113,12 +490,25 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (value ByteView, err error) {
+	ctx, span := tracing.Start(ctx, "go_cache.peer")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	defer g.stats.peerLatency.since(time.Now())
+
+	if cg, ok := peer.(PeerContextGetter); ok {
+		return cg.GetContext(ctx, g.name, key)
+	}
+	if vg, ok := peer.(PeerVersionedGetter); ok {
+		return vg.GetVersioned(g.name, key)
+	}
+	b, err := peer.Get(g.name, key)
 	if err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{b: bytes}, nil
+	return ByteView{b: b}, nil
 }
 
 // ****************************************************************
@@ -0,0 +1,173 @@
+package go_cache
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGovernorTarget   = 0.8
+	defaultGovernorInterval = time.Second
+	defaultGovernorMinBytes = 1 << 20
+
+	// adjustments within this fraction of the target are skipped to let the heap settle
+	governorDeadBand = 0.05
+	// bounds of the factor budgets are scaled by in one adjustment
+	governorMinFactor = 0.5
+	governorMaxFactor = 2
+)
+
+// GovernorOptions configures a Governor
+type GovernorOptions struct {
+	// Limit is the bytes of memory the process may use, 0 means the limit
+	// set by debug.SetMemoryLimit or GOMEMLIMIT.
+	Limit int64
+	// Target is the fraction of Limit to keep the memory under, 0.8 by default.
+	Target float64
+	// Interval between adjustments, 1s by default.
+	Interval time.Duration
+	// MinBytes is the floor of the budgets set by the governor, 1 MiB by default.
+	MinBytes int64
+	// ReadMemory returns the bytes of memory in use, read from runtime/metrics by default.
+	ReadMemory func() int64
+}
+
+// Governor sizes the byte budgets of the groups of a Registry by memory
+// pressure. When the memory in use exceeds the target, every budget shrinks
+// by the same factor, evicting values. When it falls below, the budgets of
+// groups filling them grow back by the same factor, up to the budget a group
+// was created or last reconfigured with. Groups without a budget are bounded
+// only under pressure.
+type Governor struct {
+	registry *Registry
+	opts     GovernorOptions
+
+	mu sync.Mutex // serializes the adjustments
+}
+
+func NewGovernor(r *Registry, opts *GovernorOptions) *Governor {
+	gv := &Governor{registry: r}
+	if opts != nil {
+		gv.opts = *opts
+	}
+	if gv.opts.Target <= 0 || gv.opts.Target > 1 {
+		gv.opts.Target = defaultGovernorTarget
+	}
+	if gv.opts.Interval <= 0 {
+		gv.opts.Interval = defaultGovernorInterval
+	}
+	if gv.opts.MinBytes <= 0 {
+		gv.opts.MinBytes = defaultGovernorMinBytes
+	}
+	if gv.opts.ReadMemory == nil {
+		gv.opts.ReadMemory = readMemory
+	}
+	return gv
+}
+
+// Run adjusts the budgets every Interval until ctx is done
+func (gv *Governor) Run(ctx context.Context) {
+	ticker := time.NewTicker(gv.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			gv.Adjust()
+		}
+	}
+}
+
+// limit returns the memory limit, 0 if there is none
+func (gv *Governor) limit() int64 {
+	if gv.opts.Limit > 0 {
+		return gv.opts.Limit
+	}
+	// a negative input only reads the limit
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+	return 0
+}
+
+// Adjust scales the budgets once by the ratio of the target to the memory in use
+func (gv *Governor) Adjust() {
+	limit := gv.limit()
+	memory := gv.opts.ReadMemory()
+	if limit <= 0 || memory <= 0 {
+		return
+	}
+	target := float64(limit) * gv.opts.Target
+	factor := target / float64(memory)
+	if math.Abs(factor-1) < governorDeadBand {
+		return
+	}
+	factor = math.Max(governorMinFactor, math.Min(governorMaxFactor, factor))
+
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	for _, g := range gv.registry.all() {
+		gv.adjustGroup(g, g.cacheBytes.Load(), factor)
+	}
+}
+
+func (gv *Governor) adjustGroup(g *Group, base int64, factor float64) {
+	_, bytes, budget := g.mainCache.stats()
+	if budget == 0 {
+		if factor >= 1 {
+			return
+		}
+		budget = bytes // bound an unlimited group under pressure
+	}
+	if factor > 1 && float64(bytes) < float64(budget)*0.9 {
+		return // a budget the group does not fill needs no room
+	}
+
+	next := int64(float64(budget) * factor)
+	if base > 0 && next > base {
+		next = base
+	}
+	if floor := gv.opts.MinBytes; next < floor {
+		if base > 0 && base < floor {
+			floor = base
+		}
+		next = floor
+	}
+	if next == budget {
+		return
+	}
+	slog.Info("governor resizes group", "group", g.name, "from", budget, "to", next, "factor", factor)
+	g.mainCache.setCacheBytes(next)
+}
+
+// memoryMetrics are read to compute the memory counted by the Go memory limit
+var memoryMetrics = []metrics.Sample{
+	{Name: "/memory/classes/total:bytes"},
+	{Name: "/memory/classes/heap/released:bytes"},
+}
+
+// readMemory returns the memory mapped by the runtime minus what it
+// returned to the OS, as counted against debug.SetMemoryLimit
+func readMemory() int64 {
+	samples := make([]metrics.Sample, len(memoryMetrics))
+	copy(samples, memoryMetrics)
+	metrics.Read(samples)
+	var memory int64
+	for i, s := range samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+		if i == 0 {
+			memory += int64(s.Value.Uint64())
+		} else {
+			memory -= int64(s.Value.Uint64())
+		}
+	}
+	return memory
+}
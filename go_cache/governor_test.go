@@ -0,0 +1,94 @@
+package go_cache_test
+
+import (
+	"fmt"
+	"go_cache"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGovernor(t *testing.T) {
+	r := go_cache.NewRegistry()
+	get := go_cache.GetterFunc(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("v", 97)), nil
+	})
+	bounded := r.NewGroup("bounded", 2000, get)
+	unbounded := r.NewGroup("unbounded", 0, get)
+	for i := 0; i < 20; i++ {
+		bounded.Get(fmt.Sprintf("k%02d", i))
+		unbounded.Get(fmt.Sprintf("k%02d", i))
+	}
+
+	var memory atomic.Int64
+	gov := go_cache.NewGovernor(r, &go_cache.GovernorOptions{
+		Limit:      1000,
+		Target:     0.8,
+		MinBytes:   100,
+		ReadMemory: memory.Load,
+	})
+	check := func(step string, boundedBytes, unboundedBytes int64) {
+		t.Helper()
+		if b := bounded.CacheBytes(); b != boundedBytes {
+			t.Fatalf("%s: bounded budget = %d, want %d", step, b, boundedBytes)
+		}
+		if b := unbounded.CacheBytes(); b != unboundedBytes {
+			t.Fatalf("%s: unbounded budget = %d, want %d", step, b, unboundedBytes)
+		}
+		for _, g := range []*go_cache.Group{bounded, unbounded} {
+			if s := g.Info(); s.CacheBytes > 0 && s.Bytes > s.CacheBytes {
+				t.Fatalf("%s: group %s holds %d bytes over its budget %d", step, g.Name(), s.Bytes, s.CacheBytes)
+			}
+		}
+	}
+
+	// within the dead band of the target
+	memory.Store(820)
+	gov.Adjust()
+	check("at target", 2000, 0)
+
+	// over the target, the budgets shrink by 800/1000
+	memory.Store(1000)
+	gov.Adjust()
+	check("over target", 1600, 1600)
+
+	// far over the target, by half at most, down to MinBytes
+	memory.Store(4000)
+	gov.Adjust()
+	check("far over target", 800, 800)
+	for i := 0; i < 3; i++ {
+		gov.Adjust()
+	}
+	check("floor", 100, 100)
+
+	// under the target, full budgets double up to their first budget
+	memory.Store(100)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 20; j++ {
+			bounded.Get(fmt.Sprintf("k%02d", j))
+			unbounded.Get(fmt.Sprintf("k%02d", j))
+		}
+		gov.Adjust()
+	}
+	check("under target", 1600, 1600)
+	for j := 0; j < 20; j++ {
+		bounded.Get(fmt.Sprintf("k%02d", j))
+	}
+	gov.Adjust()
+	// a budget the group does not fill is not grown
+	check("restored", 2000, 1600)
+
+	// a reconfigured budget bounds the growth instead of the first one
+	bounded.SetCacheBytes(4000)
+	memory.Store(1000)
+	gov.Adjust()
+	check("reconfigured over target", 3200, 1280)
+	memory.Store(100)
+	for i := 0; i < 40; i++ {
+		bounded.Get(fmt.Sprintf("k%02d", i))
+	}
+	gov.Adjust()
+	if b := bounded.CacheBytes(); b != 4000 {
+		t.Fatalf("reconfigured under target: bounded budget = %d, want 4000", b)
+	}
+}
@@ -0,0 +1,159 @@
+package go_cache
+
+import (
+	"go_cache/topk"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHotKeysTracked = 64
+	defaultHotKeysDecay   = time.Minute
+	defaultHotCacheBytes  = 1 << 20
+	defaultHotCacheTTL    = 10 * time.Second
+
+	// gets of a key since the last decay before it may be replicated
+	hotMinGets = 16
+	// hot keys reported by Info
+	infoHotKeys = 10
+)
+
+// HotKey is a frequently got key of a group. Its gets since the counts
+// were last halved are between Count-Err and Count.
+type HotKey struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+	Err   uint64 `json:"err,omitempty"`
+}
+
+// HotKeyOptions configures the tracking and replication of hot keys
+type HotKeyOptions struct {
+	// Tracked is the number of keys counted, 64 by default, < 0 disables tracking.
+	Tracked int
+	// Decay is the period the counts are halved in, so that the keys reported
+	// are hot lately, 1m by default.
+	Decay time.Duration
+	// Replicate is the share of the gets of a group above which a key owned
+	// by a peer is cached on this node too, sparing the owner. 0 disables it.
+	Replicate float64
+	// CacheBytes is the byte budget of the replicas, 1 MiB by default.
+	CacheBytes int64
+	// TTL bounds how long a replica is served without asking the owner,
+	// 10s by default. Writes through other nodes are seen after it at the latest.
+	TTL time.Duration
+}
+
+// hotKeys counts the gets of a group by key
+type hotKeys struct {
+	enabled atomic.Bool // spares the gets the lock until SetHotKeys
+
+	mu      sync.Mutex
+	opts    HotKeyOptions
+	tracker *topk.Tracker // created on first use unless disabled
+	decayed time.Time
+}
+
+// SetHotKeys enables the tracking of hot keys, resetting their counts, and
+// configures whether they are replicated on this node. Hot keys are not
+// tracked until it is called.
+func (g *Group) SetHotKeys(opts HotKeyOptions) {
+	if opts.Decay <= 0 {
+		opts.Decay = defaultHotKeysDecay
+	}
+	if opts.CacheBytes <= 0 {
+		opts.CacheBytes = defaultHotCacheBytes
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultHotCacheTTL
+	}
+	h := &g.hot
+	h.mu.Lock()
+	h.opts = opts
+	h.tracker = nil
+	h.enabled.Store(opts.Tracked >= 0)
+	h.mu.Unlock()
+
+	g.hotCache.setCacheBytes(opts.CacheBytes)
+	if opts.Replicate <= 0 {
+		g.hotCache.clear()
+	}
+}
+
+// HotKeys returns up to n of the most frequently got keys, all tracked if n <= 0
+func (g *Group) HotKeys(n int) []HotKey {
+	h := &g.hot
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tracker == nil {
+		return nil
+	}
+	items := h.tracker.Top(n)
+	hot := make([]HotKey, len(items))
+	for i, it := range items {
+		hot[i] = HotKey{Key: it.Key, Count: it.Count, Err: it.Err}
+	}
+	return hot
+}
+
+// record counts a get of key
+func (h *hotKeys) record(key string) {
+	if !h.enabled.Load() {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tracker == nil {
+		tracked := h.opts.Tracked
+		if tracked < 0 {
+			return
+		} else if tracked == 0 {
+			tracked = defaultHotKeysTracked
+		}
+		h.tracker = topk.New(tracked)
+		h.decayed = time.Now()
+	}
+	decay := h.opts.Decay
+	if decay <= 0 {
+		decay = defaultHotKeysDecay
+	}
+	if now := time.Now(); now.Sub(h.decayed) >= decay {
+		h.tracker.Decay()
+		h.decayed = now
+	}
+	h.tracker.Add(key, 1)
+}
+
+// replicated reports whether key is got often enough to be replicated,
+// counting only the gets it surely had
+func (h *hotKeys) replicated(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tracker == nil || h.opts.Replicate <= 0 {
+		return false
+	}
+	it, ok := h.tracker.Get(key)
+	if !ok {
+		return false
+	}
+	gets := it.Count - it.Err
+	return gets >= hotMinGets && float64(gets) >= h.opts.Replicate*float64(h.tracker.Total())
+}
+
+// replicate caches value of a key owned by a peer if the key is hot
+func (g *Group) replicate(key string, value ByteView) {
+	if !g.hot.replicated(key) {
+		return
+	}
+	g.hot.mu.Lock()
+	ttl := g.hot.opts.TTL
+	g.hot.mu.Unlock()
+
+	if expire := time.Now().Add(ttl); value.expire.IsZero() || value.expire.After(expire) {
+		value.expire = expire
+	}
+	g.hotCache.add(key, value)
+}
@@ -0,0 +1,49 @@
+package go_cache_test
+
+import (
+	"go_cache"
+	"reflect"
+	"testing"
+)
+
+func TestHotKeys(t *testing.T) {
+	g := go_cache.NewGroup("hot-keys", 0, go_cache.GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	g.Get("a")
+	if hot := g.HotKeys(0); hot != nil {
+		t.Fatalf("HotKeys = %v before tracking is enabled", hot)
+	}
+
+	g.SetHotKeys(go_cache.HotKeyOptions{})
+	for _, key := range []string{"a", "b", "a", "c", "a", "b"} {
+		g.Get(key)
+	}
+	g.GetMulti([]string{"c", "d", "c"})
+	g.Get("c")
+	want := []go_cache.HotKey{{Key: "a", Count: 3}, {Key: "c", Count: 3}}
+	if hot := g.HotKeys(2); !reflect.DeepEqual(hot, want) {
+		t.Fatalf("HotKeys = %v, want %v", hot, want)
+	}
+
+	// on a single node no key is replicated
+	g.SetHotKeys(go_cache.HotKeyOptions{Tracked: 2, Replicate: 0.1})
+	for i := 0; i < 20; i++ {
+		g.Get("a")
+	}
+	g.Get("b")
+	g.Get("c")
+	want = []go_cache.HotKey{{Key: "a", Count: 20}, {Key: "c", Count: 2, Err: 1}}
+	if hot := g.HotKeys(0); !reflect.DeepEqual(hot, want) {
+		t.Fatalf("HotKeys = %v, want %v", hot, want)
+	}
+	if info := g.Info(); info.HotItems != 0 || info.Stats.HotHits != 0 {
+		t.Fatalf("info = %+v, want no replica", info)
+	}
+
+	g.SetHotKeys(go_cache.HotKeyOptions{Tracked: -1})
+	g.Get("a")
+	if hot := g.HotKeys(0); hot != nil {
+		t.Fatalf("HotKeys = %v with tracking disabled", hot)
+	}
+}
@@ -1,38 +1,132 @@
 package go_cache
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"go_cache/consistenthash"
+	"go_cache/tracing"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ********************** server end *************************
 
 const (
 	defaultBasePath = "/_go_cache/"
-	defaultReplicas = 50 // the mutiple of virtual nodes relative to real nodes
+	// DefaultReplicas is the mutiple of virtual nodes relative to real nodes.
+	// Clients locating owners by consistenthash must use the same value.
+	DefaultReplicas = 50
+
+	defaultRetries      = 3                      // attempts to deliver an invalidation to a peer
+	defaultRetryBackoff = 100 * time.Millisecond // doubled after each failed attempt
+
+	// defaultPeerTimeout bounds a request to a peer, above defaultLeaseTTL
+	// that a lease request may wait for
+	defaultPeerTimeout = 30 * time.Second
+
+	// maxValueBytes bounds a value written over HTTP, as memcached does
+	maxValueBytes = 1 << 20
+)
+
+// headers of invalidation requests, ordered per origin node
+const (
+	headerOrigin = "X-Go-Cache-Origin"
+	headerEpoch  = "X-Go-Cache-Epoch" // start time of the origin, a new epoch restarts the sequence
+	headerSeq    = "X-Go-Cache-Seq"
+)
+
+const (
+	// headerVersion carries the version of a value in responses
+	headerVersion = "X-Go-Cache-Version"
+	// headerExpire carries the expiry of a value in unix milliseconds
+	headerExpire = "X-Go-Cache-Expire"
+	// headerFlags carries the opaque flags of a value
+	headerFlags = "X-Go-Cache-Flags"
+	// headerForwarded marks a write forwarded by a peer to the owner
+	headerForwarded = "X-Go-Cache-Forwarded"
 )
 
 type HTTPPool struct {
 	poolName string // used in log
 	basePath string // domain name and port. e.g. "https://example.net:8000"
+	registry *Registry
+	client   *http.Client // of requests to peers
 
 	// for distributed use
-	mu          sync.Mutex             // guards late two variables
-	peers       *consistenthash.Map    // select node by key. map[hashValueOfKey]key
+	mu          sync.Mutex          // guards late two variables
+	peers       *consistenthash.Map // select node by key. map[hashValueOfKey]key
+	replicas    int                 // virtual nodes of a peer in peers
+	hash        consistenthash.Hash // of keys and virtual nodes, nil means crc32
+	peerList    []string
 	httpGetters map[string]*httpGetter // map peer's baseURL to httpGetter. keyed by e.g. "http://10.0.0.2:8008"
+
+	// invalidation broadcast
+	epoch       string
+	broadcastMu sync.Mutex            // orders the broadcasts in the queues
+	seq         uint64                // guarded by broadcastMu
+	queues      map[string]*peerQueue // of the peers, guarded by broadcastMu
+	originsMu   sync.Mutex
+	origins     map[string]originState // last sequence received from each origin
+
+	limiter     atomic.Pointer[requestLimiter] // nil means unlimited
+	rateLimited atomic.Int64                   // requests refused by limiter
+
+	adminToken string // required by the admin writes when set
+}
+
+// originState tracks the invalidations received from one origin node
+type originState struct {
+	epoch string
+	seq   uint64
 }
 
 func NewHTTPPool(name string) *HTTPPool {
 	return &HTTPPool{
 		poolName: name,
 		basePath: defaultBasePath,
+		registry: defaultRegistry,
+		client:   &http.Client{Timeout: defaultPeerTimeout},
+		replicas: DefaultReplicas,
+		epoch:    strconv.FormatInt(time.Now().UnixNano(), 10),
+		origins:  make(map[string]originState),
+	}
+}
+
+// SetRegistry makes the pool serve the groups of r instead of the default
+// Registry. It must be called before serving.
+func (p *HTTPPool) SetRegistry(r *Registry) {
+	p.registry = r
+}
+
+// SetTransport sends the requests to peers through rt, e.g. to inject faults.
+// It must be called before Set.
+func (p *HTTPPool) SetTransport(rt http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.client = &http.Client{Transport: rt, Timeout: defaultPeerTimeout}
+}
+
+// SetHash makes keys owned by peers hashed by fn, with replicas virtual
+// nodes each, instead of crc32 and DefaultReplicas. Every node must use the
+// same. It must be called before Set.
+func (p *HTTPPool) SetHash(replicas int, fn consistenthash.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if replicas <= 0 {
+		replicas = DefaultReplicas
 	}
+	p.replicas = replicas
+	p.hash = fn
 }
 
 // Set updates the HTTPPool's list of peers.
@@ -40,12 +134,28 @@ func (p *HTTPPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.peers = consistenthash.New(defaultReplicas, nil)
+	p.peers = consistenthash.New(p.replicas, p.hash)
 	p.peers.Add(peers...)
+	p.peerList = append([]string(nil), peers...)
 	p.httpGetters = make(map[string]*httpGetter, len(peers))
 	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath, client: p.client}
+	}
+
+	p.originsMu.Lock()
+	for origin := range p.origins {
+		if !slices.Contains(peers, origin) {
+			delete(p.origins, origin)
+		}
 	}
+	p.originsMu.Unlock()
+}
+
+// Peers returns the peers set by Set.
+func (p *HTTPPool) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.peerList...)
 }
 
 // PickPeer picks a peer according key
@@ -62,6 +172,138 @@ func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 
 var _ PeerPicker = (*HTTPPool)(nil)
 
+// Invalidate tells every other peer to drop key from its caches. Deliveries
+// are retried with backoff, and the error reports the peers that missed it.
+// Each peer receives the invalidations in sequence order, a slow peer
+// delaying only its own.
+func (p *HTTPPool) Invalidate(group string, key string) error {
+	p.mu.Lock()
+	getters := make(map[string]*httpGetter, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.poolName {
+			getters[peer] = getter
+		}
+	}
+	p.mu.Unlock()
+
+	// the sequence is numbered and queued at once, and sent outside the lock
+	p.broadcastMu.Lock()
+	p.seq++
+	header := http.Header{}
+	header.Set(headerOrigin, p.poolName)
+	header.Set(headerEpoch, p.epoch)
+	header.Set(headerSeq, strconv.FormatUint(p.seq, 10))
+	if p.adminToken != "" {
+		header.Set("Authorization", "Bearer "+p.adminToken)
+	}
+
+	if p.queues == nil {
+		p.queues = make(map[string]*peerQueue)
+	}
+	for peer := range p.queues {
+		if _, ok := getters[peer]; !ok {
+			delete(p.queues, peer)
+		}
+	}
+	deliveries := make(map[string]*delivery, len(getters))
+	for peer, getter := range getters {
+		q, ok := p.queues[peer]
+		if !ok {
+			q = new(peerQueue)
+			p.queues[peer] = q
+		}
+		d := &delivery{getter: getter, group: group, key: key, header: header, done: make(chan error, 1)}
+		q.push(d)
+		deliveries[peer] = d
+	}
+	p.broadcastMu.Unlock()
+
+	var errs []error
+	for peer, d := range deliveries {
+		if err := <-d.done; err != nil {
+			errs = append(errs, fmt.Errorf("invalidate %s on %s: %w", key, peer, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// delivery is an invalidation queued for a peer
+type delivery struct {
+	getter *httpGetter
+	group  string
+	key    string
+	header http.Header
+	done   chan error // receives the outcome
+}
+
+// peerQueue sends the invalidations to one peer one at a time, in order
+type peerQueue struct {
+	mu      sync.Mutex
+	pending []*delivery
+	running bool // a goroutine is sending pending
+}
+
+func (q *peerQueue) push(d *delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, d)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *peerQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		d := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		d.done <- d.getter.invalidate(d.group, d.key, d.header)
+	}
+}
+
+var _ PeerInvalidator = (*HTTPPool)(nil)
+
+// fromPeer reports whether r is an invalidation broadcast by a peer: its
+// origin is in the peer list and it carries the admin token, if one is set.
+// Other requests cannot claim an origin.
+func (p *HTTPPool) fromPeer(r *http.Request) bool {
+	origin := r.Header.Get(headerOrigin)
+	if r.Method != http.MethodDelete || origin == "" || !p.hasAdminToken(r) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Contains(p.peerList, origin)
+}
+
+// observeSeq records an invalidation from origin and reports whether
+// some earlier ones were never received.
+func (p *HTTPPool) observeSeq(origin string, epoch string, seq uint64) (gap bool) {
+	p.originsMu.Lock()
+	defer p.originsMu.Unlock()
+
+	last, ok := p.origins[origin]
+	if !ok || last.epoch != epoch {
+		// nothing cached here can predate a sequence we have never seen
+		p.origins[origin] = originState{epoch: epoch, seq: seq}
+		return false
+	}
+	if seq <= last.seq { // duplicate of a retried delivery
+		return false
+	}
+	p.origins[origin] = originState{epoch: epoch, seq: seq}
+	return seq != last.seq+1
+}
+
 func (p *HTTPPool) Log(format string, v ...interface{}) {
 	slog.Info(fmt.Sprintf("[Server %s] %s", p.poolName, fmt.Sprintf(format, v...)))
 }
@@ -73,57 +315,416 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	p.Log("%s %s", r.Method, r.URL.Path)
 
-	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
-	if len(parts) != 2 {
-		http.Error(w, "must have group name and key", http.StatusBadRequest)
+	escaped := strings.TrimPrefix(r.URL.EscapedPath(), p.basePath)
+	// group names starting with '_' are reserved for internal endpoints
+	internal := strings.HasPrefix(escaped, "_")
+	endpoint, rest, _ := strings.Cut(escaped, "/")
+
+	// dropping broadcast invalidations would leave stale values behind, and
+	// an overloaded node must still be observable and reconfigurable
+	broadcast := p.fromPeer(r)
+	exempt := broadcast || internal && (endpoint == "_metrics" || endpoint == "_admin")
+	if !exempt && !p.allowRequest(r) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
 		return
 	}
 
-	groupName := parts[0]
-	key := parts[1]
+	if internal {
+		p.serveInternal(w, r, endpoint, rest)
+		return
+	}
+	groupName, key, err := splitKeyPath(escaped, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	group := GetGroup(groupName)
+	if r.Method == http.MethodDelete {
+		p.serveInvalidate(w, r, groupName, key, broadcast)
+		return
+	}
+
+	group := p.registry.GetGroup(groupName)
 	if group == nil {
 		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
 		return
 	}
 
+	switch r.Method {
+	case http.MethodPut:
+		p.serveSet(w, r, group, key)
+		return
+	case http.MethodPatch:
+		p.serveTouch(w, r, group, key)
+		return
+	}
+
+	if r.URL.Query().Has("peek") {
+		view, ok := group.peekLocally(key)
+		if !ok {
+			http.Error(w, "not cached: "+key, http.StatusNotFound)
+			return
+		}
+		writeValue(w, view)
+		return
+	}
+
 	// the operation of truly get value
-	view, err := group.Get(key)
+	group.stats.serverRequests.Add(1)
+	ctx := r.Context()
+	if sc, err := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader)); err == nil {
+		ctx = tracing.ContextWithSpanContext(ctx, sc)
+	}
+	ctx, span := tracing.Start(ctx, "go_cache.ServeHTTP")
+	view, err := group.GetContext(ctx, key)
+	span.SetError(err)
+	span.End()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serveError(w, err)
 		return
 	}
 
+	writeValue(w, view)
+}
+
+// serveInternal dispatches "<basePath>_<endpoint>/<rest>", rest is escaped
+func (p *HTTPPool) serveInternal(w http.ResponseWriter, r *http.Request, endpoint string, escaped string) {
+	if endpoint == "_lease" {
+		p.serveLease(w, r, escaped)
+		return
+	}
+	rest, err := url.PathUnescape(escaped)
+	if err != nil {
+		http.Error(w, "bad path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch endpoint {
+	case "_keys":
+		p.serveKeys(w, r, rest)
+	case "_admin":
+		p.serveAdmin(w, r, rest)
+	case "_metrics":
+		p.serveMetrics(w, r)
+	default:
+		http.Error(w, "no such endpoint: "+endpoint, http.StatusNotFound)
+	}
+}
+
+func writeValue(w http.ResponseWriter, view ByteView) {
 	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(headerVersion, strconv.FormatUint(view.Version(), 10))
+	if !view.expire.IsZero() {
+		w.Header().Set(headerExpire, strconv.FormatInt(view.expire.UnixMilli(), 10))
+	}
+	if view.flags != 0 {
+		w.Header().Set(headerFlags, strconv.FormatUint(uint64(view.flags), 10))
+	}
 	w.Write(view.ByteSlice())
 }
 
+// serveError answers a failed request, shed ones with 503 so that peers fall back
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrVersionMismatch):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, ErrOverloaded):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serveSet stores the request body, "?version=N" makes it a compare-and-set,
+// "?ttl=1m" overrides the group's TTL and "?flags=N" sets the flags
+func (p *HTTPPool) serveSet(w http.ResponseWriter, r *http.Request, group *Group, key string) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	req := &SetRequest{Value: body}
+	if v := r.URL.Query().Get("version"); v != "" {
+		if req.Version, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "bad version: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.CheckVersion = true
+	}
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		if req.TTL, err = time.ParseDuration(v); err != nil {
+			http.Error(w, "bad ttl: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("flags"); v != "" {
+		flags, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			http.Error(w, "bad flags: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Flags = uint32(flags)
+	}
+
+	// a forwarded write is executed here even if the peer lists disagree,
+	// the caller picked this node as owner
+	var view ByteView
+	if r.Header.Get(headerForwarded) != "" || group.owns(key) {
+		view, err = group.setLocally(key, req)
+	} else {
+		view, err = group.Store(key, req)
+	}
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+
+	writeValue(w, view)
+}
+
+// readBody reads a value from the request body, answering the request when
+// it fails or the value is over maxValueBytes
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// serveTouch changes the expiry of a value by "?ttl=1m", 0 means never expire
+func (p *HTTPPool) serveTouch(w http.ResponseWriter, r *http.Request, group *Group, key string) {
+	ttl, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+	if err != nil {
+		http.Error(w, "bad ttl: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	if r.Header.Get(headerForwarded) != "" || group.owns(key) {
+		ok = group.expireLocally(key, ttl)
+	} else if ok, err = group.Expire(key, ttl); err != nil {
+		serveError(w, err)
+		return
+	}
+
+	if !ok {
+		http.Error(w, "not cached: "+key, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveInvalidate drops key on this node when the request is broadcast by a
+// peer, or on every node when it comes from a client.
+func (p *HTTPPool) serveInvalidate(w http.ResponseWriter, r *http.Request, groupName string, key string, broadcast bool) {
+	if broadcast {
+		origin := r.Header.Get(headerOrigin)
+		seq, err := strconv.ParseUint(r.Header.Get(headerSeq), 10, 64)
+		if err != nil {
+			http.Error(w, "bad sequence: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.observeSeq(origin, r.Header.Get(headerEpoch), seq) {
+			// the key of a lost invalidation is unknown, drop everything to stay consistent
+			p.Log("invalidation gap from %s before seq %d, purge all groups", origin, seq)
+			p.registry.Purge()
+		}
+	}
+
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+	if broadcast {
+		group.Remove(key)
+	} else if err := group.Invalidate(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ********************** client end *************************
 
 type httpGetter struct {
 	baseURL string
+	client  *http.Client
 }
 
 func (h *httpGetter) Get(group string, key string) ([]byte, error) {
-	url := fmt.Sprintf("%v%v/%v",
-		h.baseURL, url.QueryEscape(group), url.QueryEscape(key))
-	res, err := http.Get(url)
+	view, err := h.GetContext(context.Background(), group, key)
+	return view.b, err
+}
+
+func (h *httpGetter) GetVersioned(group string, key string) (ByteView, error) {
+	return h.GetContext(context.Background(), group, key)
+}
+
+// GetContext sends the span in ctx along as a traceparent header
+func (h *httpGetter) GetContext(ctx context.Context, group string, key string) (ByteView, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+KeyPath(group, key, nil), nil)
+	if err != nil {
+		return ByteView{}, err
+	}
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		req.Header.Set(tracing.TraceparentHeader, sc.Traceparent())
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return readValue(res)
+}
+
+func (h *httpGetter) Set(group string, key string, req *SetRequest) (ByteView, error) {
+	query := make(url.Values)
+	if req.CheckVersion {
+		query.Set("version", strconv.FormatUint(req.Version, 10))
+	}
+	if req.TTL > 0 {
+		query.Set("ttl", req.TTL.String())
+	}
+	if req.Flags != 0 {
+		query.Set("flags", strconv.FormatUint(uint64(req.Flags), 10))
+	}
+	r, err := http.NewRequest(http.MethodPut, h.baseURL+KeyPath(group, key, query), bytes.NewReader(req.Value))
+	if err != nil {
+		return ByteView{}, err
+	}
+	r.Header.Set(headerForwarded, "1")
+	res, err := h.client.Do(r)
+	if err != nil {
+		return ByteView{}, err
+	}
+	if res.StatusCode == http.StatusPreconditionFailed {
+		res.Body.Close()
+		return ByteView{}, ErrVersionMismatch
+	}
+	return readValue(res)
+}
+
+// Peek asks for the value of key with "?peek=1", answered from the cache only
+func (h *httpGetter) Peek(group string, key string) (ByteView, bool, error) {
+	res, err := h.client.Get(h.baseURL + KeyPath(group, key, url.Values{"peek": {"1"}}))
+	if err != nil {
+		return ByteView{}, false, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return ByteView{}, false, nil
+	}
+	view, err := readValue(res)
+	return view, err == nil, err
+}
+
+func (h *httpGetter) Touch(group string, key string, ttl time.Duration) (bool, error) {
+	query := url.Values{"ttl": {ttl.String()}}
+	r, err := http.NewRequest(http.MethodPatch, h.baseURL+KeyPath(group, key, query), nil)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	r.Header.Set(headerForwarded, "1")
+	res, err := h.client.Do(r)
+	if err != nil {
+		return false, err
 	}
 	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, httpStatusError(res)
+}
+
+// readValue decodes a value written by writeValue and closes the body
+func readValue(res *http.Response) (ByteView, error) {
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned: %v", res.Status)
+		return ByteView{}, httpStatusError(res)
 	}
 
-	bytes, err := io.ReadAll(res.Body)
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %v", err)
+		return ByteView{}, fmt.Errorf("reading response body: %v", err)
+	}
+	view := ByteView{b: body}
+	view.version, _ = strconv.ParseUint(res.Header.Get(headerVersion), 10, 64)
+	if ms, err := strconv.ParseInt(res.Header.Get(headerExpire), 10, 64); err == nil {
+		view.expire = time.UnixMilli(ms)
+	}
+	if flags, err := strconv.ParseUint(res.Header.Get(headerFlags), 10, 32); err == nil {
+		view.flags = uint32(flags)
+	}
+	return view, nil
+}
+
+// httpStatusError describes a failed response, wrapping ErrOverloaded when the
+// peer sheds load so that callers fall back instead of failing
+func httpStatusError(res *http.Response) error {
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+		return fmt.Errorf("%w: server returned: %v", ErrOverloaded, res.Status)
+	}
+	return fmt.Errorf("server returned: %v", res.Status)
+}
+
+// invalidate sends a DELETE to the peer, retrying failures with backoff
+func (h *httpGetter) invalidate(group string, key string, header http.Header) error {
+	url := h.baseURL + KeyPath(group, key, nil)
+
+	var err error
+	backoff := defaultRetryBackoff
+	for attempt := 0; attempt < defaultRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = h.delete(url, header); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (h *httpGetter) delete(url string, header http.Header) error {
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	// a peer without the group has nothing to drop
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("server returned: %v", res.Status)
 	}
-	return bytes, nil
+	return nil
 }
 
 // check if struct `httpGetter` is interface `PeerGetter`
 var _ PeerGetter = (*httpGetter)(nil)
+var _ PeerVersionedGetter = (*httpGetter)(nil)
+var _ PeerContextGetter = (*httpGetter)(nil)
+var _ PeerSetter = (*httpGetter)(nil)
+var _ PeerPeeker = (*httpGetter)(nil)
@@ -0,0 +1,258 @@
+package go_cache_test
+
+import (
+	"go_cache"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInvalidate(t *testing.T) {
+	loads := 0
+	g := go_cache.NewGroup("invalidate", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+
+	remote := go_cache.NewHTTPPool("remote")
+	srv := httptest.NewServer(remote)
+	defer srv.Close()
+
+	local := go_cache.NewHTTPPool("local")
+	local.Set("local", srv.URL)
+
+	g.Get("k1")
+	if err := local.Invalidate("invalidate", "k1"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	g.Get("k1")
+	if loads != 2 {
+		t.Fatalf("expect k1 loaded again after invalidation, loads = %d", loads)
+	}
+}
+
+func TestInvalidateGap(t *testing.T) {
+	loads := 0
+	g := go_cache.NewGroup("invalidate-gap", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+
+	remote := go_cache.NewHTTPPool("remote")
+	remote.Set("remote", "origin")
+	srv := httptest.NewServer(remote)
+	defer srv.Close()
+
+	send := func(origin, seq string) {
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/_go_cache/invalidate-gap/other", nil)
+		req.Header.Set("X-Go-Cache-Origin", origin)
+		req.Header.Set("X-Go-Cache-Epoch", "1")
+		req.Header.Set("X-Go-Cache-Seq", seq)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("seq %s: status %s", seq, res.Status)
+		}
+	}
+
+	tests := []struct {
+		origin string
+		seq    string
+		loads  int // loads after getting k1 again
+	}{
+		{"origin", "1", 1},   // first message from origin
+		{"origin", "2", 1},   // in order
+		{"origin", "2", 1},   // duplicate
+		{"origin", "4", 2},   // seq 3 lost, purge everything
+		{"intruder", "1", 2}, // not a peer, a plain invalidation
+		{"intruder", "9", 2},
+	}
+	for _, tt := range tests {
+		g.Get("k1")
+		send(tt.origin, tt.seq)
+		g.Get("k1")
+		if loads != tt.loads {
+			t.Fatalf("after seq %s from %s loads = %d, want %d", tt.seq, tt.origin, loads, tt.loads)
+		}
+	}
+}
+
+func TestInvalidateToken(t *testing.T) {
+	loads := 0
+	g := go_cache.NewGroup("invalidate-token", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+
+	remote := go_cache.NewHTTPPool("remote")
+	remote.SetAdminToken("s3cret")
+	srv := httptest.NewServer(remote)
+	defer srv.Close()
+	local := go_cache.NewHTTPPool("local")
+	local.SetAdminToken("s3cret")
+	local.Set("local", srv.URL)
+	remote.Set(srv.URL, "local")
+
+	g.Get("k1")
+	if err := local.Invalidate("invalidate-token", "k1"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	g.Get("k1")
+	if loads != 2 {
+		t.Fatalf("expect k1 loaded again after invalidation, loads = %d", loads)
+	}
+
+	// a gap claimed without the token purges nothing
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/_go_cache/invalidate-token/other", nil)
+	req.Header.Set("X-Go-Cache-Origin", "local")
+	req.Header.Set("X-Go-Cache-Epoch", "forged")
+	req.Header.Set("X-Go-Cache-Seq", "1")
+	if res, err := http.DefaultClient.Do(req); err == nil {
+		res.Body.Close()
+	}
+	req.Header.Set("X-Go-Cache-Seq", "9")
+	if res, err := http.DefaultClient.Do(req); err == nil {
+		res.Body.Close()
+	}
+	g.Get("k1")
+	if loads != 2 {
+		t.Fatalf("expect k1 kept after forged invalidations, loads = %d", loads)
+	}
+}
+
+func TestInvalidateOrder(t *testing.T) {
+	const n = 20
+	var mu sync.Mutex
+	var seqs []int
+	got := make(chan struct{})
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seq, _ := strconv.Atoi(r.Header.Get("X-Go-Cache-Seq"))
+		mu.Lock()
+		seqs = append(seqs, seq)
+		if len(seqs) == n {
+			close(got)
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer fast.Close()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow.Close()
+
+	local := go_cache.NewHTTPPool("local")
+	local.Set(fast.URL, slow.URL)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := local.Invalidate("invalidate-order", "k1"); err != nil {
+				t.Errorf("Invalidate: %v", err)
+			}
+		}()
+	}
+
+	// the slow peer holds back neither the broadcasts nor the other peers
+	<-got
+	close(release)
+	wg.Wait()
+	for i, seq := range seqs {
+		if seq != i+1 {
+			t.Fatalf("sequences received %v, want 1 to %d in order", seqs, n)
+		}
+	}
+}
+
+func TestLeaseFill(t *testing.T) {
+	g := go_cache.NewGroup("lease-fill", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	pool := go_cache.NewHTTPPool("owner")
+
+	do := func(method, key, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/_go_cache/_lease/lease-fill/"+key+"?token="+token, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		pool.ServeHTTP(rec, req)
+		return rec
+	}
+	lease := func(key string) uint64 {
+		rec := do(http.MethodPost, key, "", "")
+		token, err := strconv.ParseUint(rec.Header().Get("X-Go-Cache-Lease"), 10, 64)
+		if rec.Code != http.StatusAccepted || err != nil {
+			t.Fatalf("lease %s: status %d, token %q", key, rec.Code, rec.Header().Get("X-Go-Cache-Lease"))
+		}
+		return token
+	}
+
+	// a token cannot be guessed from the previous one
+	t1, t2 := lease("k1"), lease("k2")
+	if rec := do(http.MethodPut, "k2", strconv.FormatUint(t1+1, 10), "forged"); rec.Code != http.StatusConflict {
+		t.Fatalf("fill with a guessed token status %d, want 409", rec.Code)
+	}
+	if rec := do(http.MethodPut, "k1", strconv.FormatUint(t1, 10), strings.Repeat("x", 2<<20)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("fill of a huge value status %d, want 413", rec.Code)
+	}
+	if rec := do(http.MethodPut, "k2", strconv.FormatUint(t2, 10), "v2"); rec.Code != http.StatusOK {
+		t.Fatalf("fill status %d", rec.Code)
+	}
+	if v, err := g.Get("k2"); err != nil || v.String() != "v2" {
+		t.Fatalf("Get(k2) = %s, %v, want the filled value", v, err)
+	}
+}
+
+func TestPeerCompareAndSet(t *testing.T) {
+	go_cache.NewGroup("peer-cas", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("loaded"), nil
+		}))
+
+	srv := httptest.NewServer(go_cache.NewHTTPPool("remote"))
+	defer srv.Close()
+
+	pool := go_cache.NewHTTPPool("local")
+	pool.Set(srv.URL)
+	peer, ok := pool.PickPeer("k1")
+	if !ok {
+		t.Fatal("expect remote peer picked")
+	}
+
+	getter := peer.(go_cache.PeerVersionedGetter)
+	loaded, err := getter.GetVersioned("peer-cas", "k1")
+	if err != nil || loaded.String() != "loaded" || loaded.Version() == 0 {
+		t.Fatalf("Get(k1) = %s@%d, %v", loaded, loaded.Version(), err)
+	}
+
+	setter := peer.(go_cache.PeerSetter)
+	req := &go_cache.SetRequest{Value: []byte("v1"), CheckVersion: true, Version: loaded.Version()}
+	view, err := setter.Set("peer-cas", "k1", req)
+	if err != nil || view.Version() <= loaded.Version() {
+		t.Fatalf("Set(k1) = %s@%d, %v", view, view.Version(), err)
+	}
+	if _, err := setter.Set("peer-cas", "k1", req); err != go_cache.ErrVersionMismatch {
+		t.Fatalf("stale Set(k1) err = %v, want ErrVersionMismatch", err)
+	}
+	if got, _ := getter.GetVersioned("peer-cas", "k1"); got.String() != "v1" || got.Version() != view.Version() {
+		t.Fatalf("Get(k1) = %s@%d, want v1@%d", got, got.Version(), view.Version())
+	}
+
+	huge := httptest.NewRequest(http.MethodPut, "/_go_cache/peer-cas/k1", strings.NewReader(strings.Repeat("x", 2<<20)))
+	rec := httptest.NewRecorder()
+	go_cache.NewHTTPPool("remote").ServeHTTP(rec, huge)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Set of a huge value status %d, want 413", rec.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package go_cache
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MaxKeyLen bounds the bytes of a key, so that its escaped form fits in the
+// request line limits of common proxies.
+const MaxKeyLen = 2048
+
+// ErrInvalidKey is returned for an empty key or one longer than MaxKeyLen.
+var ErrInvalidKey = errors.New("invalid key")
+
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key is required", ErrInvalidKey)
+	}
+	if len(key) > MaxKeyLen {
+		return fmt.Errorf("%w: %d bytes, over %d", ErrInvalidKey, len(key), MaxKeyLen)
+	}
+	return nil
+}
+
+// KeyPath locates key of group relative to the base path of an HTTPPool.
+// Keys are arbitrary bytes, path escaped as "<group>/<key>", or in the query
+// as "<group>?key=<key>" when cleaning the path, as http.ServeMux does, would
+// alter them, e.g. "a/../b" or "a//b". query is added to the result, if any.
+func KeyPath(group string, key string, query url.Values) string {
+	u := url.PathEscape(group)
+	if pathSafe(key) {
+		u += "/" + url.PathEscape(key)
+	} else {
+		if query == nil {
+			query = make(url.Values)
+		}
+		query.Set("key", key)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// pathSafe reports whether key survives the cleaning of a path
+func pathSafe(key string) bool {
+	p := "/g/" + key
+	clean := path.Clean(p)
+	// http.ServeMux keeps a trailing slash
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean == p
+}
+
+// splitKeyPath decodes the group and key of a request, from the escaped path
+// "<group>/<key>" or from "<group>" and the query "?key=", see KeyPath.
+func splitKeyPath(escaped string, query url.Values) (group string, key string, err error) {
+	escapedGroup, escapedKey, hasKey := strings.Cut(escaped, "/")
+	if group, err = url.PathUnescape(escapedGroup); err != nil {
+		return "", "", fmt.Errorf("bad group: %v", err)
+	}
+	switch {
+	case hasKey && query.Has("key"):
+		return "", "", fmt.Errorf("%w: key both in path and query", ErrInvalidKey)
+	case hasKey:
+		if key, err = url.PathUnescape(escapedKey); err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+	default:
+		key = query.Get("key")
+	}
+	return group, key, checkKey(key)
+}
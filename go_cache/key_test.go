@@ -0,0 +1,91 @@
+package go_cache_test
+
+import (
+	"errors"
+	"go_cache"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyEncoding(t *testing.T) {
+	go_cache.NewGroup("key-encoding", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	// behind a mux, which cleans paths
+	mux := http.NewServeMux()
+	mux.Handle("/_go_cache/", go_cache.NewHTTPPool("remote"))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	pool := go_cache.NewHTTPPool("local")
+	var hashed int
+	pool.SetHash(10, func(data []byte) uint32 {
+		hashed++
+		return uint32(len(data))
+	})
+	pool.Set(srv.URL)
+	peer, ok := pool.PickPeer("k")
+	if !ok || hashed != 11 {
+		t.Fatalf("PickPeer = %v, hashed %d times, want the remote peer by the custom hash", ok, hashed)
+	}
+	setter := peer.(go_cache.PeerSetter)
+
+	keys := []string{
+		"a b", "a+b", "a/b", "a//b", "a/../b", "a/./b", "/a", "a/", "/", "..", ".",
+		"%2F", "100%", "?q=1&key=x#f", "é", "\x00\xff\n",
+		strings.Repeat("k", go_cache.MaxKeyLen),
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		b := make([]byte, 1+r.Intn(32))
+		r.Read(b)
+		keys = append(keys, string(b))
+	}
+	for _, key := range keys {
+		if v, err := peer.Get("key-encoding", key); err != nil || string(v) != key {
+			t.Fatalf("Get(%q) = %q, %v", key, v, err)
+		}
+		if _, err := setter.Set("key-encoding", key, &go_cache.SetRequest{Value: []byte("set " + key)}); err != nil {
+			t.Fatalf("Set(%q): %v", key, err)
+		}
+		if v, err := peer.Get("key-encoding", key); err != nil || string(v) != "set "+key {
+			t.Fatalf("Get(%q) after Set = %q, %v", key, v, err)
+		}
+		if ok, err := setter.Touch("key-encoding", key, time.Minute); !ok || err != nil {
+			t.Fatalf("Touch(%q) = %v, %v", key, ok, err)
+		}
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/_go_cache/key-encoding/a%20b", http.StatusOK},
+		{"/_go_cache/key-encoding?key=a%2F..%2Fb", http.StatusOK},
+		{"/_go_cache/key-encoding", http.StatusBadRequest},
+		{"/_go_cache/key-encoding/", http.StatusBadRequest},
+		{"/_go_cache/key-encoding/a?key=b", http.StatusBadRequest},
+		{"/_go_cache/key-encoding/" + strings.Repeat("k", go_cache.MaxKeyLen+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		res, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, res.StatusCode, tt.status)
+		}
+	}
+
+	g := go_cache.GetGroup("key-encoding")
+	if _, err := g.Get(strings.Repeat("k", go_cache.MaxKeyLen+1)); !errors.Is(err, go_cache.ErrInvalidKey) {
+		t.Fatalf("Get of a long key err = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,308 @@
+package go_cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultScanLimit = 100
+	maxScanLimit     = 10000
+)
+
+// EnableKeyIndex keeps the keys cached by this node in order, so that Scan
+// walks only the keys matching the literal prefix of its pattern instead of
+// sorting all of them. The index is updated along with the cache.
+func (g *Group) EnableKeyIndex() {
+	g.mainCache.enableIndex()
+}
+
+// Scan returns up to limit keys cached in memory by this node that match
+// pattern and sort after cursor, in lexical order. next is the cursor of
+// the following page, empty after the last one. limit <= 0 means all.
+//
+// In pattern, '*' matches any sequence of bytes, '?' any single byte and
+// '\' escapes the next byte, e.g. "user:42:*".
+func (g *Group) Scan(pattern string, cursor string, limit int) (keys []string, next string, err error) {
+	if err := checkPattern(pattern); err != nil {
+		return nil, "", err
+	}
+	prefix := patternPrefix(pattern)
+
+	// one more to tell whether a next page exists
+	collect := func(key string) bool {
+		if !matchPattern(pattern, key) {
+			return true
+		}
+		keys = append(keys, key)
+		return limit <= 0 || len(keys) <= limit
+	}
+
+	if !g.mainCache.scan(prefix, cursor, collect) {
+		all := g.mainCache.keys(0)
+		sort.Strings(all)
+		for _, key := range all {
+			if strings.HasPrefix(key, prefix) && key > cursor && g.mainCache.contains(key) && !collect(key) {
+				break
+			}
+		}
+	}
+
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+		next = keys[limit-1]
+	}
+	return keys, next, nil
+}
+
+// DeletePattern removes the keys matching pattern, see Scan, from the caches
+// of this node and returns how many were removed.
+func (g *Group) DeletePattern(pattern string) (int, error) {
+	keys, _, err := g.Scan(pattern, "", 0)
+	if err != nil {
+		return 0, err
+	}
+	if d := g.disk.Load(); d != nil {
+		for _, key := range d.Keys() {
+			if matchPattern(pattern, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, key := range g.hotCache.keys(0) {
+		if matchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	n := 0
+	for _, key := range keys {
+		if g.Remove(key) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func checkPattern(pattern string) error {
+	// escapes pair up from the start of the trailing run of '\'
+	if n := len(pattern) - len(strings.TrimRight(pattern, `\`)); n%2 == 1 {
+		return fmt.Errorf("bad pattern %q: trailing escape", pattern)
+	}
+	return nil
+}
+
+// patternPrefix returns the literal bytes before the first wildcard
+func patternPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*', '?':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteByte(pattern[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// matchPattern reports whether key matches the whole pattern
+func matchPattern(pattern, key string) bool {
+	// backtrack to the last '*' on mismatch
+	p, k := 0, 0
+	star, starKey := -1, 0
+	for k < len(key) {
+		if p < len(pattern) {
+			switch c := pattern[p]; {
+			case c == '*':
+				star, starKey = p, k
+				p++
+				continue
+			case c == '?':
+				p++
+				k++
+				continue
+			case c == '\\' && p+1 < len(pattern):
+				if pattern[p+1] == key[k] {
+					p += 2
+					k++
+					continue
+				}
+			case c == key[k]:
+				p++
+				k++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starKey++
+		p, k = star+1, starKey
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// ScanResult is the response of the admin key endpoints
+type ScanResult struct {
+	Keys    []string `json:"keys,omitempty"`
+	Next    string   `json:"next,omitempty"`    // cursor of the following page
+	Deleted int      `json:"deleted,omitempty"` // keys removed by a delete
+	Errors  []string `json:"errors,omitempty"`  // peers that failed to answer
+}
+
+// serveKeyScan serves "<basePath>_admin/groups/<name>/keys"
+//
+//	GET    ?pattern=user:42:*&cursor=user:42:a&limit=100   scan the keys cached by every node
+//	DELETE ?pattern=user:42:*                              remove the keys from every node
+//
+// A request is fanned out to the peers unless it has "local=1".
+func (p *HTTPPool) serveKeyScan(w http.ResponseWriter, r *http.Request, g *Group) {
+	query := r.URL.Query()
+	pattern := query.Get("pattern")
+	if pattern == "" {
+		pattern = "*"
+	}
+	if err := checkPattern(pattern); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := defaultScanLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxScanLimit {
+			http.Error(w, fmt.Sprintf("bad limit %q", v), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	cursor := query.Get("cursor")
+	local := query.Get("local") == "1"
+
+	var res ScanResult
+	switch r.Method {
+	case http.MethodGet:
+		res.Keys, res.Next, _ = g.Scan(pattern, cursor, limit)
+	case http.MethodDelete:
+		if query.Get("pattern") == "" {
+			http.Error(w, "pattern is required", http.StatusBadRequest)
+			return
+		}
+		res.Deleted, _ = g.DeletePattern(pattern)
+		p.Log("delete keys %s of group %s", pattern, g.name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if local {
+		writeJSON(w, res)
+		return
+	}
+
+	query.Set("pattern", pattern)
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("local", "1")
+	results := p.fanOut(r.Context(), r.Method, "_admin/groups/"+url.PathEscape(g.name)+"/keys?"+query.Encode())
+
+	// each node answered its first keys after cursor, so the first
+	// limit of their union are the first limit of the cluster
+	more := res.Next != ""
+	for _, peer := range results {
+		if peer.err != nil {
+			res.Errors = append(res.Errors, peer.err.Error())
+			continue
+		}
+		res.Keys = append(res.Keys, peer.res.Keys...)
+		res.Deleted += peer.res.Deleted
+		more = more || peer.res.Next != ""
+	}
+	if r.Method == http.MethodGet {
+		res.Keys, res.Next = mergeKeys(res.Keys, limit, more)
+	}
+	writeJSON(w, res)
+}
+
+// mergeKeys sorts and dedupes keys, returning the first limit and the cursor after them
+func mergeKeys(keys []string, limit int, more bool) ([]string, string) {
+	sort.Strings(keys)
+	out := keys[:0]
+	for _, key := range keys {
+		if len(out) == 0 || key != out[len(out)-1] {
+			out = append(out, key)
+		}
+	}
+	if len(out) > limit {
+		out, more = out[:limit], true
+	}
+	if more && len(out) > 0 {
+		return out, out[len(out)-1]
+	}
+	return out, ""
+}
+
+type peerScan struct {
+	res ScanResult
+	err error
+}
+
+// fanOut sends a request for path under the base path to every other peer
+func (p *HTTPPool) fanOut(ctx context.Context, method string, path string) []peerScan {
+	var peers []string
+	for _, peer := range p.Peers() {
+		if peer != p.poolName {
+			peers = append(peers, peer)
+		}
+	}
+
+	results := make([]peerScan, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			results[i].err = p.requestPeer(ctx, method, peer+p.basePath+path, &results[i].res)
+			if results[i].err != nil {
+				results[i].err = fmt.Errorf("%s: %w", peer, results[i].err)
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+	return results
+}
+
+func (p *HTTPPool) requestPeer(ctx context.Context, method string, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	if p.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.adminToken)
+	}
+	p.mu.Lock()
+	client := p.client
+	p.mu.Unlock()
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return httpStatusError(res)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,101 @@
+package go_cache_test
+
+import (
+	"go_cache"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "scan-indexed"
+		}
+		g := go_cache.NewGroup(name, 0, go_cache.GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte(key), nil
+			}))
+		g.Get("user:1:name")
+		if indexed {
+			g.EnableKeyIndex()
+		}
+		for _, key := range []string{"user:42:name", "user:42:age", "user:420:name", "user:1:age", "team:*", "team:1"} {
+			g.Get(key)
+		}
+		g.Remove("user:1:age")
+
+		tests := []struct {
+			pattern string
+			cursor  string
+			limit   int
+			keys    []string
+			next    string
+		}{
+			{"*", "", 0, []string{"team:*", "team:1", "user:1:name", "user:420:name", "user:42:age", "user:42:name"}, ""},
+			{"user:42:*", "", 0, []string{"user:42:age", "user:42:name"}, ""},
+			{"user:42*", "", 2, []string{"user:420:name", "user:42:age"}, "user:42:age"},
+			{"user:42*", "user:42:age", 2, []string{"user:42:name"}, ""},
+			{"user:?:*", "", 0, []string{"user:1:name"}, ""},
+			{"*:name", "", 0, []string{"user:1:name", "user:420:name", "user:42:name"}, ""},
+			{`team:\*`, "", 0, []string{"team:*"}, ""},
+			{"user:1", "", 0, nil, ""},
+		}
+		for _, tt := range tests {
+			keys, next, err := g.Scan(tt.pattern, tt.cursor, tt.limit)
+			if err != nil || !reflect.DeepEqual(keys, tt.keys) || next != tt.next {
+				t.Errorf("%s: Scan(%q, %q, %d) = %q, %q, %v, want %q, %q",
+					name, tt.pattern, tt.cursor, tt.limit, keys, next, err, tt.keys, tt.next)
+			}
+		}
+		if _, _, err := g.Scan(`user\`, "", 0); err == nil {
+			t.Errorf("%s: expect an error for a trailing escape", name)
+		}
+
+		if n, err := g.DeletePattern("user:42*"); n != 3 || err != nil {
+			t.Errorf("%s: DeletePattern = %d, %v, want 3", name, n, err)
+		}
+		if keys, _, _ := g.Scan("user:*", "", 0); !reflect.DeepEqual(keys, []string{"user:1:name"}) {
+			t.Errorf("%s: keys after DeletePattern = %q", name, keys)
+		}
+	}
+}
+
+func TestScanConcurrentWrites(t *testing.T) {
+	g := go_cache.NewGroup("scan-concurrent", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	// holds back the events of the first removal
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	g.Subscribe(func(e go_cache.Event) {
+		if e.Reason == go_cache.EventRemove {
+			once.Do(func() {
+				close(blocked)
+				<-release
+			})
+		}
+	})
+	g.EnableKeyIndex()
+	g.Set("k1", []byte("v1"))
+
+	// the removal is delivered after the write that follows it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Remove("k1")
+	}()
+	<-blocked
+	g.Set("k1", []byte("v2"))
+	close(release)
+	<-done
+
+	if keys, _, _ := g.Scan("*", "", 0); !reflect.DeepEqual(keys, []string{"k1"}) {
+		t.Fatalf("Scan = %q, want the cached k1", keys)
+	}
+	if n, _ := g.DeletePattern("k*"); n != 1 {
+		t.Fatalf("DeletePattern = %d, want 1", n)
+	}
+}
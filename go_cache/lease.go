@@ -0,0 +1,350 @@
+package go_cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultLeaseTTL bounds how long a node may hold the lease of a key before
+// another node is allowed to load it
+const defaultLeaseTTL = 10 * time.Second
+
+// headerLease carries the token of a lease granted by the owner of a key
+const headerLease = "X-Go-Cache-Lease"
+
+// ErrLeaseExpired is returned when filling a lease that expired or was
+// revoked by a write or an invalidation of its key.
+var ErrLeaseExpired = errors.New("lease expired")
+
+// lease is held by the node loading a key for the cluster
+type lease struct {
+	token   uint64
+	version uint64 // cached for the key when granted, 0 if none
+	expire  time.Time
+	done    chan struct{} // closed when the lease is filled, released, revoked or taken over
+}
+
+// leases are the load leases granted by the owner of keys
+type leases struct {
+	mu    sync.Mutex
+	m     map[string]*lease
+	swept time.Time // when the expired leases were last dropped
+}
+
+// newLeaseToken returns a random token, so that only the holder can fill a
+// lease. 0 means no lease.
+func newLeaseToken() uint64 {
+	var b [8]byte
+	for {
+		rand.Read(b[:])
+		if token := binary.LittleEndian.Uint64(b[:]); token != 0 {
+			return token
+		}
+	}
+}
+
+// acquire grants the lease of key unless a live one is held, which is
+// returned instead. version is the one cached for key.
+func (ls *leases) acquire(key string, ttl time.Duration, version uint64) (l *lease, granted bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	if l, ok := ls.m[key]; ok {
+		if now.Before(l.expire) {
+			return l, false
+		}
+		close(l.done) // wake the waiters of the expired lease
+		delete(ls.m, key)
+	}
+	if ls.m == nil {
+		ls.m = make(map[string]*lease)
+	}
+	// holders may never come back for keys no one asks for again
+	if now.Sub(ls.swept) >= ttl {
+		for k, l := range ls.m {
+			if !now.Before(l.expire) {
+				close(l.done)
+				delete(ls.m, k)
+			}
+		}
+		ls.swept = now
+	}
+	l = &lease{token: newLeaseToken(), version: version, expire: now.Add(ttl), done: make(chan struct{})}
+	ls.m[key] = l
+	return l, true
+}
+
+// release ends the lease of key if it is still token, reporting whether it was
+func (ls *leases) release(key string, token uint64) bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	l, ok := ls.m[key]
+	if !ok || l.token != token {
+		return false
+	}
+	delete(ls.m, key)
+	close(l.done)
+	return true
+}
+
+// revoke ends the lease of key, the value being loaded under it is outdated
+func (ls *leases) revoke(key string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if l, ok := ls.m[key]; ok {
+		delete(ls.m, key)
+		close(l.done)
+	}
+}
+
+// SetLeaseTTL bounds how long a node may take to load a key under the lease
+// granted by this node, after which another one takes over. 0 restores the default.
+func (g *Group) SetLeaseTTL(ttl time.Duration) {
+	g.leaseTTL.Store(int64(ttl))
+}
+
+func (g *Group) getLeaseTTL() time.Duration {
+	if ttl := time.Duration(g.leaseTTL.Load()); ttl > 0 {
+		return ttl
+	}
+	return defaultLeaseTTL
+}
+
+// grantLease runs on the owner of key. It returns the cached value when
+// fresh, or else the lease to load it. A stale value is returned while
+// another node holds the lease, otherwise the requester waits for the
+// holder to fill, release or lose it.
+func (g *Group) grantLease(ctx context.Context, key string) (ByteView, uint64, error) {
+	if g.deleted.Load() {
+		return ByteView{}, 0, ErrGroupDeleted
+	}
+	for {
+		v, ok := g.mainCache.get(key, time.Duration(g.staleIfError.Load()))
+		if ok && !v.expired(time.Now()) {
+			return v, 0, nil
+		}
+		if !ok {
+			if v, ok := g.getFromDisk(ctx, key); ok {
+				return v, 0, nil
+			}
+		}
+		var version uint64
+		if ok {
+			version = v.version
+		}
+		l, granted := g.leases.acquire(key, g.getLeaseTTL(), version)
+		if granted {
+			g.stats.leasesGranted.Add(1)
+			return ByteView{}, l.token, nil
+		}
+		if ok {
+			g.stats.staleHits.Add(1)
+			return v, 0, nil
+		}
+
+		g.stats.leaseWaits.Add(1)
+		timer := time.NewTimer(time.Until(l.expire))
+		select {
+		case <-l.done:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ByteView{}, 0, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// fillLease stores the value loaded under the lease of key
+func (g *Group) fillLease(key string, token uint64, b []byte) (ByteView, error) {
+	if g.deleted.Load() {
+		return ByteView{}, ErrGroupDeleted
+	}
+	g.leases.mu.Lock()
+	l, ok := g.leases.m[key]
+	if !ok || l.token != token {
+		g.leases.mu.Unlock()
+		return ByteView{}, ErrLeaseExpired
+	}
+	delete(g.leases.m, key)
+	g.leases.mu.Unlock()
+
+	// waiters look for the value once woken up
+	value := g.populateCache(key, g.newEntry(cloneBytes(b), 0), l.version)
+	close(l.done)
+	return value, nil
+}
+
+// loadWithLease loads key locally under the lease of its owner, which sheds
+// the load itself. It reports false when the owner cannot coordinate.
+func (g *Group) loadWithLease(ctx context.Context, peer PeerGetter, key string) (ByteView, bool, error) {
+	leaser, ok := peer.(PeerLeaser)
+	if !ok {
+		return ByteView{}, false, nil
+	}
+	value, token, err := leaser.Lease(ctx, g.name, key)
+	if err != nil {
+		slog.Info("[GeeCache] Failed to get lease from peer", "key", key, "err", err)
+		return ByteView{}, false, nil
+	}
+	if token == 0 {
+		g.stats.peerLoads.Add(1)
+		return value, true, nil
+	}
+
+	// the value is cached by the owner, under the version it gives
+	b, err := g.callGetter(ctx, key)
+	if err != nil {
+		g.stats.localLoadErrs.Add(1)
+		if err := leaser.Release(g.name, key, token); err != nil {
+			slog.Info("[GeeCache] Failed to release lease", "key", key, "err", err)
+		}
+		return ByteView{}, true, err
+	}
+	g.stats.localLoads.Add(1)
+	value, err = leaser.Fill(g.name, key, token, b)
+	if err != nil {
+		// the value is fresh for this caller, only the owner misses it
+		slog.Info("[GeeCache] Failed to fill lease", "key", key, "err", err)
+		return g.newEntry(b, 0), true, nil
+	}
+	return value, true, nil
+}
+
+// serveLease serves "<basePath>_lease/<group>/<key>"
+//
+//	POST                 ask for the value or the lease to load it
+//	PUT    ?token=N      fill the lease with the request body
+//	DELETE ?token=N      release the lease
+func (p *HTTPPool) serveLease(w http.ResponseWriter, r *http.Request, escaped string) {
+	groupName, key, err := splitKeyPath(escaped, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+
+	var token uint64
+	if r.Method != http.MethodPost {
+		var err error
+		if token, err = strconv.ParseUint(r.URL.Query().Get("token"), 10, 64); err != nil {
+			http.Error(w, "bad token: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		view, token, err := group.grantLease(r.Context(), key)
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		if token != 0 {
+			w.Header().Set(headerLease, strconv.FormatUint(token, 10))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writeValue(w, view)
+	case http.MethodPut:
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		view, err := group.fillLease(key, token, body)
+		if errors.Is(err, ErrLeaseExpired) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			serveError(w, err)
+			return
+		}
+		writeValue(w, view)
+	case http.MethodDelete:
+		group.leases.release(key, token)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *httpGetter) leaseURL(group string, key string, query url.Values) string {
+	return h.baseURL + "_lease/" + KeyPath(group, key, query)
+}
+
+func (h *httpGetter) Lease(ctx context.Context, group string, key string) (ByteView, uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.leaseURL(group, key, nil), nil)
+	if err != nil {
+		return ByteView{}, 0, err
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return ByteView{}, 0, err
+	}
+	if res.StatusCode == http.StatusAccepted {
+		res.Body.Close()
+		token, err := strconv.ParseUint(res.Header.Get(headerLease), 10, 64)
+		if err != nil || token == 0 {
+			return ByteView{}, 0, fmt.Errorf("bad lease token %q", res.Header.Get(headerLease))
+		}
+		return ByteView{}, token, nil
+	}
+	view, err := readValue(res)
+	return view, 0, err
+}
+
+func (h *httpGetter) Fill(group string, key string, token uint64, value []byte) (ByteView, error) {
+	u := h.leaseURL(group, key, url.Values{"token": {strconv.FormatUint(token, 10)}})
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(value))
+	if err != nil {
+		return ByteView{}, err
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return ByteView{}, err
+	}
+	if res.StatusCode == http.StatusConflict {
+		res.Body.Close()
+		return ByteView{}, ErrLeaseExpired
+	}
+	return readValue(res)
+}
+
+func (h *httpGetter) Release(group string, key string, token uint64) error {
+	u := h.leaseURL(group, key, url.Values{"token": {strconv.FormatUint(token, 10)}})
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode != http.StatusNoContent {
+		return httpStatusError(res)
+	}
+	return nil
+}
+
+var _ PeerLeaser = (*httpGetter)(nil)
@@ -0,0 +1,130 @@
+package go_cache
+
+import (
+	"go_cache/ratelimit"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limits protect a node from floods of requests. Zero rates mean unlimited.
+type Limits struct {
+	Rate        float64 // requests per second accepted by the node
+	Burst       int
+	ClientRate  float64 // requests per second accepted from one client address
+	ClientBurst int
+}
+
+// requestLimiter applies Limits to the requests of an HTTPPool
+type requestLimiter struct {
+	limits Limits
+	global *ratelimit.Limiter
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	l    *ratelimit.Limiter
+	last time.Time // last request of the client
+}
+
+func newRequestLimiter(limits Limits) *requestLimiter {
+	return &requestLimiter{
+		limits:    limits,
+		global:    ratelimit.New(limits.Rate, limits.Burst),
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of client and one from the global
+// bucket, or none when either is empty
+func (l *requestLimiter) allow(client string) bool {
+	if l.limits.ClientRate <= 0 {
+		return l.global.Allow()
+	}
+	c := l.client(client)
+	if !c.Allow() {
+		return false
+	}
+	if !l.global.Allow() {
+		c.Refund()
+		return false
+	}
+	return true
+}
+
+func (l *requestLimiter) client(client string) *ratelimit.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	// a bucket idle for long enough is full again, as good as a new one
+	idle := time.Duration(float64(max(l.limits.ClientBurst, 1)) / l.limits.ClientRate * float64(time.Second))
+	if now.Sub(l.lastSweep) > idle {
+		for k, c := range l.clients {
+			if now.Sub(c.last) > idle {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{l: ratelimit.New(l.limits.ClientRate, l.limits.ClientBurst)}
+		l.clients[client] = c
+	}
+	c.last = now
+	return c.l
+}
+
+// SetLimits limits the rate of requests served by the pool, leases and key
+// listings included. Requests over the limits are answered with 429 Too Many
+// Requests. Invalidations broadcast by peers, the metrics and the admin
+// endpoints are never limited.
+func (p *HTTPPool) SetLimits(limits Limits) {
+	p.limiter.Store(newRequestLimiter(limits))
+}
+
+// allowRequest reports whether r is within the limits of the pool
+func (p *HTTPPool) allowRequest(r *http.Request) bool {
+	l := p.limiter.Load()
+	if l == nil {
+		return true
+	}
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if l.allow(client) {
+		return true
+	}
+	p.rateLimited.Add(1)
+	return false
+}
+
+// SetMaxLoads bounds the number of concurrent loads from the Getter, 0 means
+// unbounded. Loads over the bound fail with ErrOverloaded, and Get serves
+// the stale value if stale-if-error allows.
+func (g *Group) SetMaxLoads(n int) {
+	g.maxLoads.Store(int64(n))
+}
+
+// acquireLoad takes a slot for a load from the Getter, release must be
+// called once the load is done.
+func (g *Group) acquireLoad() (release func(), err error) {
+	limit := g.maxLoads.Load()
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	if g.loading.Add(1) > limit {
+		g.loading.Add(-1)
+		g.stats.loadsShed.Add(1)
+		return nil, ErrOverloaded
+	}
+	return func() { g.loading.Add(-1) }, nil
+}
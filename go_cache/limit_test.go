@@ -0,0 +1,137 @@
+package go_cache_test
+
+import (
+	"errors"
+	"go_cache"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	go_cache.NewGroup("rate-limit", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	pool := go_cache.NewHTTPPool("local")
+	pool.Set("local", "peer")
+	pool.SetLimits(go_cache.Limits{ClientRate: 0.001, ClientBurst: 2})
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+
+	do := func(method string, origin string) int {
+		req, _ := http.NewRequest(method, srv.URL+"/_go_cache/rate-limit/k1", nil)
+		if origin != "" {
+			req.Header.Set("X-Go-Cache-Origin", origin)
+			req.Header.Set("X-Go-Cache-Epoch", "1")
+			req.Header.Set("X-Go-Cache-Seq", "1")
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	tests := []struct {
+		method string
+		origin string
+		status int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodGet, "", http.StatusTooManyRequests}, // burst used up
+		{http.MethodDelete, "", http.StatusTooManyRequests},
+		{http.MethodDelete, "peer", http.StatusNoContent}, // broadcast invalidations are never limited
+		{http.MethodDelete, "intruder", http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		if status := do(tt.method, tt.origin); status != tt.status {
+			t.Fatalf("request %d %s: status %d, want %d", i, tt.method, status, tt.status)
+		}
+	}
+
+	// the metrics endpoint is not limited, leases are
+	for path, status := range map[string]int{
+		"/_go_cache/_metrics":             http.StatusOK,
+		"/_go_cache/_lease/rate-limit/k1": http.StatusTooManyRequests,
+	} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Fatalf("%s status %s, want %d", path, res.Status, status)
+		}
+	}
+}
+
+func TestMaxLoads(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	g := go_cache.NewGroup("max-loads", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "slow" {
+				close(started)
+				<-release
+			}
+			return []byte(key), nil
+		}))
+	g.SetMaxLoads(1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.Get("slow")
+	}()
+	<-started
+
+	if _, err := g.Get("k1"); !errors.Is(err, go_cache.ErrOverloaded) {
+		t.Fatalf("Get(k1) err = %v, want ErrOverloaded", err)
+	}
+
+	// peers are told to fall back by 503
+	srv := httptest.NewServer(go_cache.NewHTTPPool("remote"))
+	defer srv.Close()
+	pool := go_cache.NewHTTPPool("local")
+	pool.Set(srv.URL)
+	peer, _ := pool.PickPeer("k1")
+	if _, err := peer.Get("max-loads", "k1"); !errors.Is(err, go_cache.ErrOverloaded) {
+		t.Fatalf("peer Get(k1) err = %v, want ErrOverloaded", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if v, err := g.Get("k1"); err != nil || v.String() != "k1" {
+		t.Fatalf("Get(k1) = %s, %v after the slow load", v, err)
+	}
+	if shed := g.Stats().LoadsShed; shed != 2 {
+		t.Fatalf("loads shed = %d, want 2", shed)
+	}
+}
+
+func TestPeerOverloadedFallback(t *testing.T) {
+	g := go_cache.NewGroup("peer-overloaded", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("local"), nil
+		}))
+
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}))
+	defer busy.Close()
+	pool := go_cache.NewHTTPPool("local")
+	pool.Set(busy.URL)
+	g.RegisterPeers(pool)
+
+	if v, err := g.Get("k1"); err != nil || v.String() != "local" {
+		t.Fatalf("Get(k1) = %s, %v, want loaded locally", v, err)
+	}
+	if s := g.Stats(); s.PeerRejects != 1 || s.PeerErrors != 0 || s.LocalLoads != 1 {
+		t.Fatalf("stats = %+v, want a peer reject and a local load", s)
+	}
+}
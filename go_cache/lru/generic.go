@@ -0,0 +1,193 @@
+package lru
+
+// LRU is a generic LRU cache. It is not safe for concurrent access.
+// Entries are stored in a slice and linked by index (an intrusive doubly
+// linked list), so values are neither boxed nor allocated one by one.
+type LRU[K comparable, V any] struct {
+	maxBytes int64
+	nBytes   int64
+	sizer    func(key K, value V) int64 // memory space of an entry
+
+	nodes []node[K, V] // nodes[0] is the sentinel: next is the newest, prev the oldest
+	free  int32        // head of the free node list chained by next. 0 means empty
+	index map[K]int32  // O(1) search
+
+	// optional and executed when entry is purged
+	onEvicted func(key K, value V)
+}
+
+type node[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next int32
+}
+
+// NewLRU creates a LRU cache limited to maxBytes as measured by sizer.
+// maxBytes 0 means no limit. A nil sizer counts every entry as 1 byte.
+func NewLRU[K comparable, V any](maxBytes int64, sizer func(K, V) int64, onEvicted func(K, V)) *LRU[K, V] {
+	if sizer == nil {
+		sizer = func(K, V) int64 { return 1 }
+	}
+	return &LRU[K, V]{
+		maxBytes:  maxBytes,
+		sizer:     sizer,
+		nodes:     make([]node[K, V], 1),
+		index:     make(map[K]int32),
+		onEvicted: onEvicted,
+	}
+}
+
+// Get looks up a key's value and marks it as the most recently used.
+func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
+	if i, ok := c.index[key]; ok {
+		c.moveToFront(i)
+		return c.nodes[i].value, true
+	}
+	return value, false
+}
+
+// Peek looks up a key's value without updating its recency.
+func (c *LRU[K, V]) Peek(key K) (value V, ok bool) {
+	if i, ok := c.index[key]; ok {
+		return c.nodes[i].value, true
+	}
+	return value, false
+}
+
+// Contains reports whether key is in the cache without updating its recency.
+func (c *LRU[K, V]) Contains(key K) bool {
+	_, ok := c.index[key]
+	return ok
+}
+
+// Add inserts or updates a value and marks it as the most recently used,
+// evicting the oldest entries while the cache is over its limit.
+func (c *LRU[K, V]) Add(key K, value V) {
+	if i, ok := c.index[key]; ok {
+		n := &c.nodes[i]
+		c.nBytes += c.sizer(key, value) - c.sizer(key, n.value)
+		n.value = value
+		c.moveToFront(i)
+	} else {
+		i := c.alloc()
+		c.nodes[i].key, c.nodes[i].value = key, value
+		c.pushFront(i)
+		c.index[key] = i
+		c.nBytes += c.sizer(key, value)
+	}
+
+	for c.maxBytes != 0 && c.maxBytes < c.nBytes {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest removes the least recently used entry.
+func (c *LRU[K, V]) RemoveOldest() {
+	if i := c.nodes[0].prev; i != 0 {
+		c.remove(i)
+	}
+}
+
+// Remove deletes key from the cache. onEvicted is called if the key was present.
+func (c *LRU[K, V]) Remove(key K) bool {
+	if i, ok := c.index[key]; ok {
+		c.remove(i)
+		return true
+	}
+	return false
+}
+
+// Keys returns all keys ordered from the most to the least recently used.
+func (c *LRU[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.index))
+	for i := c.nodes[0].next; i != 0; i = c.nodes[i].next {
+		keys = append(keys, c.nodes[i].key)
+	}
+	return keys
+}
+
+// Range calls fn for each entry from the most to the least recently used,
+// stopping early if fn returns false. fn must not modify the cache.
+func (c *LRU[K, V]) Range(fn func(key K, value V) bool) {
+	for i := c.nodes[0].next; i != 0; i = c.nodes[i].next {
+		if !fn(c.nodes[i].key, c.nodes[i].value) {
+			return
+		}
+	}
+}
+
+// Clear removes all entries from the oldest one, calling onEvicted for each of them.
+func (c *LRU[K, V]) Clear() {
+	for len(c.index) > 0 {
+		c.RemoveOldest()
+	}
+}
+
+// SetMaxBytes changes the limit, evicting the oldest entries while the cache
+// is over it. 0 means no limit.
+func (c *LRU[K, V]) SetMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	for c.maxBytes != 0 && c.maxBytes < c.nBytes {
+		c.RemoveOldest()
+	}
+}
+
+// MaxBytes returns the limit. 0 means no limit.
+func (c *LRU[K, V]) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+// Len returns the number of entries.
+func (c *LRU[K, V]) Len() int {
+	return len(c.index)
+}
+
+// Bytes returns the memory space used by entries as measured by the sizer.
+func (c *LRU[K, V]) Bytes() int64 {
+	return c.nBytes
+}
+
+func (c *LRU[K, V]) remove(i int32) {
+	n := c.nodes[i]
+	c.unlink(i)
+	delete(c.index, n.key)
+	c.nBytes -= c.sizer(n.key, n.value)
+
+	// release references held by the node and put it into free list
+	c.nodes[i] = node[K, V]{next: c.free}
+	c.free = i
+
+	if c.onEvicted != nil {
+		c.onEvicted(n.key, n.value)
+	}
+}
+
+func (c *LRU[K, V]) alloc() int32 {
+	if i := c.free; i != 0 {
+		c.free = c.nodes[i].next
+		return i
+	}
+	c.nodes = append(c.nodes, node[K, V]{})
+	return int32(len(c.nodes) - 1)
+}
+
+func (c *LRU[K, V]) pushFront(i int32) {
+	head := c.nodes[0].next
+	c.nodes[i].prev, c.nodes[i].next = 0, head
+	c.nodes[head].prev = i
+	c.nodes[0].next = i
+}
+
+func (c *LRU[K, V]) unlink(i int32) {
+	prev, next := c.nodes[i].prev, c.nodes[i].next
+	c.nodes[prev].next = next
+	c.nodes[next].prev = prev
+}
+
+func (c *LRU[K, V]) moveToFront(i int32) {
+	if c.nodes[0].next == i {
+		return
+	}
+	c.unlink(i)
+	c.pushFront(i)
+}
@@ -0,0 +1,84 @@
+package lru_test
+
+import (
+	"go_cache/lru"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestLRUGeneric(t *testing.T) {
+	var evicted []int
+	sizer := func(key int, value []byte) int64 { return int64(len(value)) }
+	cache := lru.NewLRU(6, sizer, func(key int, value []byte) {
+		evicted = append(evicted, key)
+	})
+
+	cache.Add(1, []byte("aa"))
+	cache.Add(2, []byte("bb"))
+	cache.Add(3, []byte("cc"))
+	cache.Get(1)
+	cache.Add(4, []byte("dd"))
+
+	tests := []struct {
+		key  int
+		want string
+		ok   bool
+	}{
+		{1, "aa", true},
+		{2, "", false},
+		{3, "cc", true},
+		{4, "dd", true},
+	}
+	for _, tt := range tests {
+		v, ok := cache.Peek(tt.key)
+		if ok != tt.ok || string(v) != tt.want {
+			t.Errorf("Peek(%d) = %q, %v, want %q, %v", tt.key, v, ok, tt.want, tt.ok)
+		}
+	}
+	if want := []int{2}; !reflect.DeepEqual(evicted, want) {
+		t.Fatalf("evicted %v, want %v", evicted, want)
+	}
+	if want := []int{4, 1, 3}; !reflect.DeepEqual(cache.Keys(), want) {
+		t.Fatalf("Keys() = %v, want %v", cache.Keys(), want)
+	}
+}
+
+func TestLRUReuseNodes(t *testing.T) {
+	cache := lru.NewLRU[string, int](0, nil, nil)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 10; i++ {
+			cache.Add(strconv.Itoa(i), i)
+		}
+		for i := 0; i < 10; i += 2 {
+			cache.Remove(strconv.Itoa(i))
+		}
+		if cache.Len() != 5 || cache.Bytes() != 5 {
+			t.Fatalf("round %d: Len() = %d, Bytes() = %d, want 5, 5", round, cache.Len(), cache.Bytes())
+		}
+		if want := []string{"9", "7", "5", "3", "1"}; !reflect.DeepEqual(cache.Keys(), want) {
+			t.Fatalf("round %d: Keys() = %v, want %v", round, cache.Keys(), want)
+		}
+		cache.Clear()
+	}
+}
+
+func BenchmarkLRUAddGet(b *testing.B) {
+	cache := lru.NewLRU[int, int](1024, nil, nil)
+	for i := 0; i < b.N; i++ {
+		cache.Add(i%2048, i)
+		cache.Get((i + 1024) % 2048)
+	}
+}
+
+func BenchmarkCacheAddGet(b *testing.B) {
+	cache := lru.New(1024*8, nil)
+	keys := make([]string, 2048)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for i := 0; i < b.N; i++ {
+		cache.Add(keys[i%2048], String("v"))
+		cache.Get(keys[(i+1024)%2048])
+	}
+}
@@ -1,81 +1,90 @@
 package lru
 
-import "container/list"
-
 // Cache is a LRU cache. It is not safe for concurrent access.
-// It is a LinkedListHashMap
+// It is a thin adapter of LRU keyed by string and sized by Value.Len().
 type Cache struct {
-	maxByte int64
-	nBytes  int64
-
-	ll    *list.List               // O(1) move to font or tail
-	cache map[string]*list.Element // O(1) search
+	lru *LRU[string, Value]
 
 	// optional and executed when entry is purged
 	OnEvicted func(key string, value Value)
 }
 
-// value type in DoubleLinkedList
-type entry struct {
-	key   string
-	value Value
-}
 type Value interface {
 	Len() int // return memory space
 }
 
 func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
-	return &Cache{
-		maxByte:   maxBytes,
-		nBytes:    0,
-		ll:        list.New(),
-		cache:     make(map[string]*list.Element),
-		OnEvicted: onEvicted,
+	c := &Cache{OnEvicted: onEvicted}
+	c.lru = NewLRU(maxBytes, sizeOf, c.evicted)
+	return c
+}
+
+func sizeOf(key string, value Value) int64 {
+	return int64(len(key)) + int64(value.Len())
+}
+
+// evicted forwards to OnEvicted, which may be changed after New
+func (c *Cache) evicted(key string, value Value) {
+	if c.OnEvicted != nil {
+		c.OnEvicted(key, value)
 	}
 }
 
+// Get looks up a key's value and marks it as the most recently used.
 func (c *Cache) Get(key string) (value Value, ok bool) {
-	if ele, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ele)
-		if kv, ok := ele.Value.(*entry); ok {
-			return kv.value, true
-		} else { // assert fail
-			c.ll.Remove(ele)
-			return nil, false
-		}
-	}
-	return nil, false
+	return c.lru.Get(key)
+}
+
+// Peek looks up a key's value without updating its recency.
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	return c.lru.Peek(key)
+}
+
+// Contains reports whether key is in the cache without updating its recency.
+func (c *Cache) Contains(key string) bool {
+	return c.lru.Contains(key)
 }
 
 func (c *Cache) RemoveOldest() {
-	ele := c.ll.Back()
-	if ele != nil {
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
-		delete(c.cache, kv.key)
-		c.nBytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
-		}
-	}
+	c.lru.RemoveOldest()
+}
+
+// Remove deletes key from the cache. OnEvicted is called if the key was present.
+func (c *Cache) Remove(key string) bool {
+	return c.lru.Remove(key)
 }
 
 func (c *Cache) Add(key string, value Value) {
-	if ele, ok := c.cache[key]; ok {
-		kv := ele.Value.(*entry)
-		c.nBytes -= int64(value.Len()) - int64(kv.value.Len())
-		kv.value = value
-	} else {
-		ele := c.ll.PushFront(&entry{key: key, value: value})
-		c.cache[key] = ele
-		c.nBytes += int64(len(key)) + int64(value.Len())
-	}
+	c.lru.Add(key, value)
+}
 
-	for c.maxByte != 0 && c.maxByte < c.nBytes {
-		c.RemoveOldest()
-	}
+// Keys returns all keys ordered from the most to the least recently used.
+func (c *Cache) Keys() []string {
+	return c.lru.Keys()
+}
+
+// Range calls fn for each entry from the most to the least recently used,
+// stopping early if fn returns false. fn must not modify the cache.
+func (c *Cache) Range(fn func(key string, value Value) bool) {
+	c.lru.Range(fn)
+}
+
+// Clear removes all entries, calling OnEvicted for each of them.
+func (c *Cache) Clear() {
+	c.lru.Clear()
+}
+
+// SetMaxBytes changes the limit, evicting the oldest entries while the cache
+// is over it. 0 means no limit.
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.lru.SetMaxBytes(maxBytes)
 }
 
 func (c *Cache) Len() int {
-	return c.ll.Len()
+	return c.lru.Len()
+}
+
+// Bytes returns the memory space used by keys and values.
+func (c *Cache) Bytes() int64 {
+	return c.lru.Bytes()
 }
@@ -61,3 +61,148 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestAddUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		adds      [][2]string
+		wantKeys  []string
+		wantBytes int64
+	}{
+		{"grow value", [][2]string{{"k1", "v"}, {"k2", "v"}, {"k1", "value"}}, []string{"k1", "k2"}, 10},
+		{"shrink value", [][2]string{{"k1", "value"}, {"k2", "v"}, {"k1", "v"}}, []string{"k1", "k2"}, 6},
+		{"same value", [][2]string{{"k1", "v"}, {"k1", "v"}}, []string{"k1"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := lru.New(0, nil)
+			for _, kv := range tt.adds {
+				cache.Add(kv[0], String(kv[1]))
+			}
+			if got := cache.Keys(); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("Keys() = %v, want %v", got, tt.wantKeys)
+			}
+			if got := cache.Bytes(); got != tt.wantBytes {
+				t.Errorf("Bytes() = %d, want %d", got, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestUpdateKeepsRecentlyUsed(t *testing.T) {
+	cache := lru.New(int64(len("k1v1k2v2")), nil)
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+	cache.Add("k1", String("v1"))
+	cache.Add("k3", String("v3"))
+
+	if !cache.Contains("k1") || cache.Contains("k2") {
+		t.Fatalf("expect k2 evicted after k1 updated, got keys %v", cache.Keys())
+	}
+}
+
+func TestPeekContains(t *testing.T) {
+	cache := lru.New(0, nil)
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"k1", "v1", true},
+		{"k2", "v2", true},
+		{"k3", "", false},
+	}
+	for _, tt := range tests {
+		v, ok := cache.Peek(tt.key)
+		if ok != tt.ok || (ok && string(v.(String)) != tt.value) {
+			t.Errorf("Peek(%q) = %v, %v, want %q, %v", tt.key, v, ok, tt.value, tt.ok)
+		}
+		if got := cache.Contains(tt.key); got != tt.ok {
+			t.Errorf("Contains(%q) = %v, want %v", tt.key, got, tt.ok)
+		}
+	}
+
+	if got, want := cache.Keys(), []string{"k2", "k1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Peek must not change recency, keys = %v, want %v", got, want)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		removed  bool
+		wantKeys []string
+	}{
+		{"front", "k3", true, []string{"k2", "k1"}},
+		{"middle", "k2", true, []string{"k3", "k1"}},
+		{"back", "k1", true, []string{"k3", "k2"}},
+		{"missing", "k4", false, []string{"k3", "k2", "k1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evicted []string
+			cache := lru.New(0, func(key string, value lru.Value) {
+				evicted = append(evicted, key)
+			})
+			cache.Add("k1", String("v1"))
+			cache.Add("k2", String("v2"))
+			cache.Add("k3", String("v3"))
+
+			if got := cache.Remove(tt.key); got != tt.removed {
+				t.Fatalf("Remove(%q) = %v, want %v", tt.key, got, tt.removed)
+			}
+			if got := cache.Keys(); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("Keys() = %v, want %v", got, tt.wantKeys)
+			}
+			if tt.removed && !reflect.DeepEqual(evicted, []string{tt.key}) {
+				t.Errorf("OnEvicted called with %v, want [%s]", evicted, tt.key)
+			}
+			if want := int64(4 * len(tt.wantKeys)); cache.Bytes() != want {
+				t.Errorf("Bytes() = %d, want %d", cache.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestKeysRange(t *testing.T) {
+	cache := lru.New(0, nil)
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+	cache.Add("k3", String("v3"))
+	cache.Get("k1")
+
+	want := []string{"k1", "k3", "k2"}
+	if got := cache.Keys(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+
+	var ranged []string
+	cache.Range(func(key string, value lru.Value) bool {
+		ranged = append(ranged, key)
+		return len(ranged) < 2
+	})
+	if !reflect.DeepEqual(ranged, want[:2]) {
+		t.Fatalf("Range visited %v, want %v", ranged, want[:2])
+	}
+}
+
+func TestClear(t *testing.T) {
+	var evicted []string
+	cache := lru.New(0, func(key string, value lru.Value) {
+		evicted = append(evicted, key)
+	})
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+	cache.Clear()
+
+	if cache.Len() != 0 || cache.Bytes() != 0 {
+		t.Fatalf("Clear left %d entries and %d bytes", cache.Len(), cache.Bytes())
+	}
+	if want := []string{"k1", "k2"}; !reflect.DeepEqual(evicted, want) {
+		t.Fatalf("OnEvicted called with %v, want %v", evicted, want)
+	}
+}
@@ -0,0 +1,377 @@
+// Package memcache serves a go_cache group over the memcached text protocol.
+//
+// Supported commands are get, gets, set, add, delete, touch, stats, version
+// and quit. Flags are stored along with the values, the cas unique of gets
+// is the version of the value, and exptime is mapped onto the TTL, in set
+// and add as in touch: 0 is the group's TTL, up to 30 days it is relative
+// seconds, above it is an absolute unix time, and a negative exptime
+// expires immediately.
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go_cache"
+	"go_cache/internal/tcpserver"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxKeyLength = 250
+	// longer command lines close the connection, this fits a get of 200 long keys
+	maxLineLength = 64 * 1024
+	maxValueSize  = 1024 * 1024
+	// data blocks of rejected values up to this size are skipped, the
+	// connection is closed beyond it
+	maxDiscardSize = 64 * maxValueSize
+	// exptime above this is an absolute unix time
+	maxRelativeExptime = 60 * 60 * 24 * 30
+)
+
+// Server is a memcached frontend of one go_cache group.
+type Server struct {
+	group string
+	srv   *tcpserver.Server
+	start time.Time
+}
+
+func NewServer(group string) *Server {
+	s := &Server{group: group, start: time.Now()}
+	s.srv = tcpserver.New(s.serveConn)
+	return s
+}
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = tcpserver.ErrServerClosed
+
+func (s *Server) ListenAndServe(addr string) error {
+	return s.srv.ListenAndServe(addr)
+}
+
+// Serve accepts connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	return s.srv.Serve(l)
+}
+
+// Close stops the listeners and closes all connections.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// errClient is a malformed command, answered with CLIENT_ERROR
+type errClient string
+
+func (e errClient) Error() string { return string(e) }
+
+// errFatal is a command after which the stream cannot be parsed any more,
+// answered with CLIENT_ERROR before closing the connection
+type errFatal string
+
+func (e errFatal) Error() string { return string(e) }
+
+var errTooLarge = errors.New("object too large for cache")
+
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReaderSize(conn, maxLineLength)
+	w := bufio.NewWriter(conn)
+	for {
+		// flush once the pipelined commands are all answered
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			w.WriteString("CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				slog.Info("[memcache] read command failed", "remote", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" {
+			w.Flush()
+			return
+		}
+
+		if err := s.exec(r, w, fields); err != nil {
+			var clientErr errClient
+			var fatal errFatal
+			if errors.As(err, &fatal) {
+				fmt.Fprintf(w, "CLIENT_ERROR %s\r\n", fatal)
+				w.Flush()
+				return
+			} else if errors.As(err, &clientErr) {
+				fmt.Fprintf(w, "CLIENT_ERROR %s\r\n", clientErr)
+			} else if err == io.ErrUnexpectedEOF {
+				return
+			} else {
+				fmt.Fprintf(w, "SERVER_ERROR %s\r\n", oneLine(err.Error()))
+			}
+		}
+	}
+}
+
+func (s *Server) exec(r *bufio.Reader, w *bufio.Writer, fields []string) error {
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "version":
+		w.WriteString("VERSION go_cache\r\n")
+		return nil
+	case "stats":
+		return s.stats(w)
+	}
+
+	g := go_cache.GetGroup(s.group)
+	if g == nil {
+		if cmd == "set" || cmd == "add" {
+			if err := discardData(r, args); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("no such group %s", s.group)
+	}
+
+	switch cmd {
+	case "get", "gets":
+		if len(args) == 0 {
+			return errClient("bad command line format")
+		}
+		if err := checkKeys(args...); err != nil {
+			return err
+		}
+		for _, key := range args {
+			view, err := g.Get(key)
+			if err != nil {
+				if !isNotFound(err) {
+					slog.Info("[memcache] get failed", "key", key, "err", err)
+				}
+				continue
+			}
+			fmt.Fprintf(w, "VALUE %s %d %d", key, view.Flags(), view.Len())
+			if cmd == "gets" {
+				fmt.Fprintf(w, " %d", view.Version())
+			}
+			w.WriteString("\r\n")
+			w.Write(view.ByteSlice())
+			w.WriteString("\r\n")
+		}
+		w.WriteString("END\r\n")
+		return nil
+
+	case "set", "add":
+		return s.store(r, w, g, cmd, args)
+
+	case "delete":
+		if len(args) < 1 || len(args) > 2 {
+			return errClient("bad command line format")
+		}
+		if err := checkKeys(args[0]); err != nil {
+			return err
+		}
+		deleted, err := g.Delete(args[0])
+		if err != nil {
+			return err
+		}
+		if deleted {
+			reply(w, args[1:], "DELETED")
+		} else {
+			reply(w, args[1:], "NOT_FOUND")
+		}
+		return nil
+
+	case "touch":
+		if len(args) < 2 || len(args) > 3 {
+			return errClient("bad command line format")
+		}
+		if err := checkKeys(args[0]); err != nil {
+			return err
+		}
+		exptime, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errClient("bad command line format")
+		}
+		ok, err := touch(g, args[0], exptime)
+		if err != nil {
+			return err
+		}
+		if ok {
+			reply(w, args[2:], "TOUCHED")
+		} else {
+			reply(w, args[2:], "NOT_FOUND")
+		}
+		return nil
+	}
+
+	w.WriteString("ERROR\r\n")
+	return nil
+}
+
+// store handles "<set|add> <key> <flags> <exptime> <bytes> [noreply]" and its data block
+func (s *Server) store(r *bufio.Reader, w *bufio.Writer, g *go_cache.Group, cmd string, args []string) error {
+	if len(args) < 4 || len(args) > 5 {
+		return errClient("bad command line format")
+	}
+	key := args[0]
+	size, err := strconv.Atoi(args[3])
+	if err != nil || size < 0 {
+		// the data block cannot be told from the next commands
+		return errFatal("bad data chunk size")
+	}
+	if size > maxValueSize {
+		if err := discardData(r, args); err != nil {
+			return err
+		}
+		return errTooLarge
+	}
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		if err := discardData(r, args); err != nil {
+			return err
+		}
+		return errClient("bad command line format")
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return errClient("bad data chunk")
+	}
+	data = data[:size]
+	if err := checkKeys(key); err != nil {
+		return err
+	}
+
+	ttl, expired := ttlOf(g, exptime)
+	if expired {
+		// stored and expired at once, drop any older value
+		if err := g.Invalidate(key); err != nil {
+			return err
+		}
+		reply(w, args[4:], "STORED")
+		return nil
+	}
+
+	req := &go_cache.SetRequest{Value: data, TTL: ttl, Flags: uint32(flags)}
+	if cmd == "add" {
+		// version 0 means the key must not be cached
+		req.CheckVersion = true
+	}
+	if _, err := g.Store(key, req); errors.Is(err, go_cache.ErrVersionMismatch) {
+		reply(w, args[4:], "NOT_STORED")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	reply(w, args[4:], "STORED")
+	return nil
+}
+
+func touch(g *go_cache.Group, key string, exptime int64) (bool, error) {
+	ttl, expired := ttlOf(g, exptime)
+	if expired {
+		return true, g.Invalidate(key)
+	}
+	return g.Expire(key, ttl)
+}
+
+// ttlOf maps a memcached exptime onto a TTL of g, 0 being the group's TTL
+func ttlOf(g *go_cache.Group, exptime int64) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime < 0:
+		return 0, true
+	case exptime == 0:
+		return g.TTL(), false
+	case exptime <= maxRelativeExptime:
+		return time.Duration(exptime) * time.Second, false
+	}
+	ttl = time.Until(time.Unix(exptime, 0))
+	return ttl, ttl <= 0
+}
+
+func (s *Server) stats(w *bufio.Writer) error {
+	stat := func(name string, value any) {
+		fmt.Fprintf(w, "STAT %s %v\r\n", name, value)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(time.Since(s.start).Seconds()))
+	stat("time", time.Now().Unix())
+	stat("version", "go_cache")
+	if g := go_cache.GetGroup(s.group); g != nil {
+		info, st := g.Info(), g.Stats()
+		stat("curr_items", info.Items)
+		stat("bytes", info.Bytes)
+		stat("limit_maxbytes", info.CacheBytes)
+		stat("cmd_get", st.Gets)
+		stat("get_hits", st.CacheHits)
+		stat("get_misses", st.Loads)
+		stat("get_errors", st.LocalLoadErrs)
+	}
+	w.WriteString("END\r\n")
+	return nil
+}
+
+// checkKeys refuses keys over the length allowed by the protocol
+func checkKeys(keys ...string) error {
+	for _, key := range keys {
+		if len(key) > maxKeyLength {
+			return errClient("key too long")
+		}
+	}
+	return nil
+}
+
+// reply writes msg unless the command ends with noreply
+func reply(w *bufio.Writer, rest []string, msg string) {
+	if len(rest) > 0 && rest[len(rest)-1] == "noreply" {
+		return
+	}
+	w.WriteString(msg + "\r\n")
+}
+
+// discardData skips the data block of a storage command that is rejected,
+// failing when its size cannot be trusted
+func discardData(r *bufio.Reader, args []string) error {
+	if len(args) < 4 {
+		return nil
+	}
+	size, err := strconv.Atoi(args[3])
+	if err != nil || size < 0 {
+		return errFatal("bad data chunk size")
+	}
+	if size > maxDiscardSize {
+		return errFatal("object too large for cache")
+	}
+	if _, err := r.Discard(size + 2); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, go_cache.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
+
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
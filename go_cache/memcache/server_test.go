@@ -0,0 +1,136 @@
+package memcache_test
+
+import (
+	"bufio"
+	"fmt"
+	"go_cache"
+	"go_cache/memcache"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	go_cache.NewGroup("memcache", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "Tom" {
+				return []byte("630"), nil
+			}
+			return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+		}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := memcache.NewServer("memcache")
+	go srv.Serve(l)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// do sends a request and reads reply lines until one of the terminators
+	do := func(req string) string {
+		if _, err := io.WriteString(conn, req); err != nil {
+			t.Fatal(err)
+		}
+		var reply strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%q: %v", req, err)
+			}
+			reply.WriteString(line)
+			switch strings.Fields(line)[0] {
+			case "VALUE", "STAT":
+				continue
+			}
+			if !strings.HasPrefix(line, "VALUE") && reply.Len() > len(line) && !strings.HasPrefix(line, "END") {
+				continue // data line of a VALUE
+			}
+			return reply.String()
+		}
+	}
+
+	tests := []struct {
+		req  string
+		want string
+	}{
+		{"get Tom\r\n", "VALUE Tom 0 3\r\n630\r\nEND\r\n"},
+		{"get Sam\r\n", "END\r\n"},
+		{"set Sam 42 0 3\r\n567\r\n", "STORED\r\n"},
+		{"get Tom Sam Nobody\r\n", "VALUE Tom 0 3\r\n630\r\nVALUE Sam 42 3\r\n567\r\nEND\r\n"},
+		{"add Sam 0 0 3\r\n568\r\n", "NOT_STORED\r\n"},
+		{"add Jack 7 100 3\r\n589\r\n", "STORED\r\n"},
+		{"get Jack\r\n", "VALUE Jack 7 3\r\n589\r\nEND\r\n"},
+		{"touch Jack 10\r\n", "TOUCHED\r\n"},
+		{"touch Nobody 10\r\n", "NOT_FOUND\r\n"},
+		{"delete Jack\r\n", "DELETED\r\n"},
+		{"delete Jack\r\n", "NOT_FOUND\r\n"},
+		{"get Jack\r\n", "END\r\n"},
+		{"set Jack 0 -1 3\r\n589\r\n", "STORED\r\n"},
+		{"get Jack\r\n", "END\r\n"},
+		{"set Sam 0 0 3 noreply\r\n569\r\nget Sam\r\n", "VALUE Sam 0 3\r\n569\r\nEND\r\n"},
+		{"set Sam 0 0 2\r\n569\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"set Sam x 0 3\r\n569\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"incr Sam 1\r\n", "ERROR\r\n"},
+		{"get Sam " + strings.Repeat("k", 251) + "\r\n", "CLIENT_ERROR key too long\r\n"},
+		{"delete " + strings.Repeat("k", 251) + "\r\n", "CLIENT_ERROR key too long\r\n"},
+		{"touch " + strings.Repeat("k", 251) + " 10\r\n", "CLIENT_ERROR key too long\r\n"},
+		{"set " + strings.Repeat("k", 251) + " 0 0 3\r\n569\r\n", "CLIENT_ERROR key too long\r\n"},
+		// the data block of a value too large is skipped, not run as commands
+		{fmt.Sprintf("set Big 0 0 %d\r\n%s\r\n", 2<<20, strings.Repeat("flush_all\r\n", 2<<20/11)+strings.Repeat("x", 2<<20%11)),
+			"SERVER_ERROR object too large for cache\r\n"},
+		{"get Sam\r\n", "VALUE Sam 0 3\r\n569\r\nEND\r\n"},
+		{"version\r\n", "VERSION go_cache\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(tt.req); got != tt.want {
+			t.Fatalf("%q = %q, want %q", tt.req, got, tt.want)
+		}
+	}
+
+	io.WriteString(conn, "gets Sam\r\n")
+	var key string
+	var flags, size int
+	var cas uint64
+	if line, _ := r.ReadString('\n'); func() bool {
+		n, _ := fmt.Sscanf(line, "VALUE %s %d %d %d", &key, &flags, &size, &cas)
+		return n != 4 || cas == 0
+	}() {
+		t.Fatalf("gets reply %q has no cas unique", line)
+	}
+	r.ReadString('\n')
+	r.ReadString('\n')
+
+	if got := do("stats\r\n"); !strings.Contains(got, "STAT curr_items ") || !strings.HasSuffix(got, "END\r\n") {
+		t.Fatalf("stats = %q", got)
+	}
+
+	// a negative size leaves the data block unknown, the connection is closed
+	if got := do("set Sam 0 0 -1\r\nget Sam\r\n"); got != "CLIENT_ERROR bad data chunk size\r\n" {
+		t.Fatalf("negative size = %q", got)
+	}
+	if _, err := r.ReadString('\n'); err != io.EOF {
+		t.Fatalf("read after a negative size err = %v, want EOF", err)
+	}
+
+	// a line that never ends is not buffered past the limit
+	conn, err = net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go io.WriteString(conn, "get "+strings.Repeat("k", 1<<20))
+	// the unread rest of the line may reset the connection after the reply
+	reply, _ := bufio.NewReader(conn).ReadString('\n')
+	if reply != "CLIENT_ERROR line too long\r\n" {
+		t.Fatalf("long line = %q", reply)
+	}
+}
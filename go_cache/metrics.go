@@ -0,0 +1,154 @@
+package go_cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metric is a family of samples in the Prometheus text format, one per group
+type metric struct {
+	name  string
+	kind  string // counter or gauge
+	help  string
+	value func(s Stats, info GroupInfo) int64
+}
+
+var groupMetrics = []metric{
+	{"go_cache_gets_total", "counter", "Get requests, including those from peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.Gets }},
+	{"go_cache_hits_total", "counter", "Gets served from the cache.",
+		func(s Stats, _ GroupInfo) int64 { return s.CacheHits }},
+	{"go_cache_misses_total", "counter", "Gets that had to load the value.",
+		func(s Stats, _ GroupInfo) int64 { return s.Loads }},
+	{"go_cache_stale_hits_total", "counter", "Expired values served because reloading failed.",
+		func(s Stats, _ GroupInfo) int64 { return s.StaleHits }},
+	{"go_cache_disk_hits_total", "counter", "Gets served from the disk tier.",
+		func(s Stats, _ GroupInfo) int64 { return s.DiskHits }},
+	{"go_cache_hot_hits_total", "counter", "Gets served from replicas of hot keys owned by peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.HotHits }},
+	{"go_cache_disk_writes_total", "counter", "Evicted values written to the disk tier.",
+		func(s Stats, _ GroupInfo) int64 { return s.DiskWrites }},
+	{"go_cache_loads_total", "counter", "Loads after deduplicating concurrent misses.",
+		func(s Stats, _ GroupInfo) int64 { return s.LoadsDeduped }},
+	{"go_cache_peer_loads_total", "counter", "Values got from peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.PeerLoads }},
+	{"go_cache_peer_errors_total", "counter", "Failed gets from peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.PeerErrors }},
+	{"go_cache_peer_rejects_total", "counter", "Gets shed by overloaded peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.PeerRejects }},
+	{"go_cache_local_loads_total", "counter", "Values got from the Getter.",
+		func(s Stats, _ GroupInfo) int64 { return s.LocalLoads }},
+	{"go_cache_local_load_errors_total", "counter", "Failed loads from the Getter.",
+		func(s Stats, _ GroupInfo) int64 { return s.LocalLoadErrs }},
+	{"go_cache_loads_shed_total", "counter", "Loads refused over the bound of concurrent loads.",
+		func(s Stats, _ GroupInfo) int64 { return s.LoadsShed }},
+	{"go_cache_server_requests_total", "counter", "Gets received from peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.ServerRequests }},
+	{"go_cache_leases_granted_total", "counter", "Leases to load owned keys granted to peers.",
+		func(s Stats, _ GroupInfo) int64 { return s.LeasesGranted }},
+	{"go_cache_lease_waits_total", "counter", "Lease requests that waited for another holder.",
+		func(s Stats, _ GroupInfo) int64 { return s.LeaseWaits }},
+	{"go_cache_evictions_total", "counter", "Values purged to keep the cache within its bytes.",
+		func(s Stats, _ GroupInfo) int64 { return s.Evictions }},
+	{"go_cache_expirations_total", "counter", "Values dropped after their TTL.",
+		func(s Stats, _ GroupInfo) int64 { return s.Expirations }},
+	{"go_cache_bytes", "gauge", "Bytes used by cached keys and values.",
+		func(_ Stats, info GroupInfo) int64 { return info.Bytes }},
+	{"go_cache_max_bytes", "gauge", "Byte budget of the cache, 0 means no limit.",
+		func(_ Stats, info GroupInfo) int64 { return info.CacheBytes }},
+	{"go_cache_items", "gauge", "Cached values.",
+		func(_ Stats, info GroupInfo) int64 { return int64(info.Items) }},
+	{"go_cache_hot_bytes", "gauge", "Bytes of replicated hot keys.",
+		func(_ Stats, info GroupInfo) int64 { return info.HotBytes }},
+	{"go_cache_disk_bytes", "gauge", "Bytes of values in the disk tier.",
+		func(_ Stats, info GroupInfo) int64 { return info.DiskBytes }},
+	{"go_cache_disk_items", "gauge", "Values in the disk tier.",
+		func(_ Stats, info GroupInfo) int64 { return int64(info.DiskItems) }},
+}
+
+// groupHistograms are latency histograms of every group
+var groupHistograms = []struct {
+	name string
+	help string
+	of   func(g *Group) *histogram
+}{
+	{"go_cache_load_duration_seconds", "Latency of loads from the Getter.",
+		func(g *Group) *histogram { return &g.stats.loadLatency }},
+	{"go_cache_peer_request_duration_seconds", "Latency of gets from peers.",
+		func(g *Group) *histogram { return &g.stats.peerLatency }},
+}
+
+// WriteMetrics writes the metrics of all groups in the Prometheus text
+// exposition format.
+func WriteMetrics(w io.Writer) error {
+	return defaultRegistry.WriteMetrics(w)
+}
+
+// WriteMetrics writes the metrics of the groups of r, see WriteMetrics.
+func (r *Registry) WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	all := r.all()
+	stats := make([]Stats, len(all))
+	infos := make([]GroupInfo, len(all))
+	for i, g := range all {
+		infos[i] = g.Info()
+		stats[i] = infos[i].Stats
+	}
+
+	for _, m := range groupMetrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for i, g := range all {
+			fmt.Fprintf(bw, "%s{group=\"%s\"} %d\n", m.name, escapeLabel(g.name), m.value(stats[i], infos[i]))
+		}
+	}
+
+	for _, h := range groupHistograms {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for _, g := range all {
+			writeHistogram(bw, h.name, escapeLabel(g.name), h.of(g))
+		}
+	}
+	return bw.Flush()
+}
+
+// writeHistogram writes the cumulative buckets, sum and count of h
+func writeHistogram(w io.Writer, name string, group string, h *histogram) {
+	var count int64
+	for i := range h.counts {
+		count += h.counts[i].Load()
+		le := "+Inf"
+		if i < len(latencyBuckets) {
+			le = strconv.FormatFloat(latencyBuckets[i], 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "%s_bucket{group=\"%s\",le=\"%s\"} %d\n", name, group, le, count)
+	}
+	sum := time.Duration(h.sum.Load()).Seconds()
+	fmt.Fprintf(w, "%s_sum{group=\"%s\"} %s\n", name, group, strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{group=\"%s\"} %d\n", name, group, count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// serveMetrics serves "<basePath>_metrics" for Prometheus scrapes
+func (p *HTTPPool) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := p.registry.WriteMetrics(w); err != nil {
+		return
+	}
+	fmt.Fprintf(w, "# HELP go_cache_rate_limited_total Requests refused by the limits of the pool.\n"+
+		"# TYPE go_cache_rate_limited_total counter\ngo_cache_rate_limited_total %d\n", p.rateLimited.Load())
+}
@@ -0,0 +1,69 @@
+package go_cache_test
+
+import (
+	"fmt"
+	"go_cache"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	g := go_cache.NewGroup("metrics", 8, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "missing" {
+				return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+			}
+			return []byte("v"), nil
+		}))
+	g.Get("k1")
+	g.Get("k1")
+	g.Get("k2")
+	g.Get("k3") // 8 bytes hold two entries, k1 is evicted
+	g.Get("missing")
+
+	srv := httptest.NewServer(go_cache.NewHTTPPool("local"))
+	defer srv.Close()
+	res, err := http.Get(srv.URL + "/_go_cache/_metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status %s: %s", res.Status, body)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %s", ct)
+	}
+
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(body), "\n") {
+		lines[line] = true
+	}
+	for _, want := range []string{
+		"# TYPE go_cache_gets_total counter",
+		`go_cache_gets_total{group="metrics"} 5`,
+		`go_cache_hits_total{group="metrics"} 1`,
+		`go_cache_misses_total{group="metrics"} 4`,
+		`go_cache_local_loads_total{group="metrics"} 3`,
+		`go_cache_local_load_errors_total{group="metrics"} 1`,
+		`go_cache_evictions_total{group="metrics"} 1`,
+		`go_cache_bytes{group="metrics"} 6`,
+		`go_cache_max_bytes{group="metrics"} 8`,
+		`go_cache_items{group="metrics"} 2`,
+		"# TYPE go_cache_load_duration_seconds histogram",
+		`go_cache_load_duration_seconds_bucket{group="metrics",le="+Inf"} 4`,
+		`go_cache_load_duration_seconds_count{group="metrics"} 4`,
+		`go_cache_peer_request_duration_seconds_count{group="metrics"} 0`,
+	} {
+		if !lines[want] {
+			t.Errorf("missing line %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Logf("metrics:\n%s", body)
+	}
+}
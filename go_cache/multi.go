@@ -0,0 +1,126 @@
+package go_cache
+
+import (
+	"context"
+	"errors"
+	"go_cache/tracing"
+	"strconv"
+	"time"
+)
+
+// MultiGetter is implemented by a Getter able to load several keys in one
+// round trip to its source, e.g. a single SQL query. Keys missing from the
+// source are left out of the result.
+type MultiGetter interface {
+	Getter
+	GetMulti(keys []string) (map[string][]byte, error)
+}
+
+// GetMulti returns the values of keys, leaving out those not found. Keys
+// owned by this node that miss the cache are loaded in one batch when the
+// Getter is a MultiGetter, the others are got one by one.
+func (g *Group) GetMulti(keys []string) (map[string]ByteView, error) {
+	return g.GetMultiContext(context.Background(), keys)
+}
+
+// GetMultiContext is like GetMulti, tracing under the span in ctx.
+func (g *Group) GetMultiContext(ctx context.Context, keys []string) (values map[string]ByteView, err error) {
+	if g.deleted.Load() {
+		return nil, ErrGroupDeleted
+	}
+	for _, key := range keys {
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, "go_cache.GetMulti")
+	span.SetAttr("group", g.name)
+	span.SetAttr("keys", strconv.Itoa(len(keys)))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	values = make(map[string]ByteView, len(keys))
+	var batch []string // owned by this node and missing the cache
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if v, ok := g.mainCache.get(key, 0); ok {
+			g.stats.gets.Add(1)
+			g.hot.record(key)
+			g.stats.cacheHits.Add(1)
+			values[key] = v
+			continue
+		}
+		if _, ok := g.getter.(MultiGetter); ok && g.owns(key) {
+			g.hot.record(key)
+			if v, ok := g.getFromDisk(ctx, key); ok {
+				g.stats.gets.Add(1)
+				g.stats.diskHits.Add(1)
+				values[key] = v
+				continue
+			}
+			g.stats.gets.Add(1)
+			g.stats.loads.Add(1)
+			batch = append(batch, key)
+			continue
+		}
+
+		v, err := g.GetContext(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		values[key] = v
+	}
+
+	if len(batch) > 0 {
+		if err := g.loadBatch(ctx, batch, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// loadBatch loads keys from the MultiGetter into the cache and values
+func (g *Group) loadBatch(ctx context.Context, keys []string, values map[string]ByteView) error {
+	_, span := tracing.Start(ctx, "go_cache.getter")
+	defer span.End()
+	span.SetAttr("keys", strconv.Itoa(len(keys)))
+
+	release, err := g.acquireLoad()
+	if err != nil {
+		span.SetError(err)
+		return err
+	}
+	g.stats.loadsDeduped.Add(int64(len(keys)))
+	seen := make([]uint64, len(keys))
+	for i, key := range keys {
+		seen[i] = g.mainCache.version(key)
+	}
+	start := time.Now()
+	loaded, err := g.getter.(MultiGetter).GetMulti(keys)
+	g.stats.loadLatency.since(start)
+	release()
+	if err != nil {
+		g.stats.localLoadErrs.Add(1)
+		span.SetError(err)
+		return err
+	}
+
+	for i, key := range keys {
+		b, ok := loaded[key]
+		if !ok {
+			continue
+		}
+		g.stats.localLoads.Add(1)
+		values[key] = g.populateCache(key, g.newEntry(b, 0), seen[i])
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package go_cache_test
+
+import (
+	"errors"
+	"fmt"
+	"go_cache"
+	"reflect"
+	"testing"
+)
+
+type multiGetter struct {
+	go_cache.GetterFunc
+	batches [][]string
+}
+
+func (m *multiGetter) GetMulti(keys []string) (map[string][]byte, error) {
+	m.batches = append(m.batches, keys)
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if v, err := m.Get(key); err == nil {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+func TestGetMulti(t *testing.T) {
+	db := map[string]string{"Tom": "630", "Jack": "589"}
+	get := go_cache.GetterFunc(func(key string) ([]byte, error) {
+		if key == "fail" {
+			return nil, errors.New("db down")
+		}
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+	})
+	str := func(values map[string]go_cache.ByteView) map[string]string {
+		m := make(map[string]string)
+		for k, v := range values {
+			m[k] = v.String()
+		}
+		return m
+	}
+	want := map[string]string{"Tom": "630", "Jack": "589"}
+
+	// one by one with a plain Getter
+	g := go_cache.NewGroup("get-multi", 0, get)
+	values, err := g.GetMulti([]string{"Tom", "Jack", "Sam", "Tom"})
+	if err != nil || !reflect.DeepEqual(str(values), want) {
+		t.Fatalf("GetMulti = %v, %v, want %v", str(values), err, want)
+	}
+	if _, err := g.GetMulti([]string{"Tom", "fail"}); err == nil {
+		t.Fatal("expect the error of a failed load")
+	}
+
+	// in one batch with a MultiGetter
+	m := &multiGetter{GetterFunc: get}
+	g = go_cache.NewGroup("get-multi-batch", 0, m)
+	g.Get("Tom")
+	values, err = g.GetMulti([]string{"Tom", "Jack", "Sam", "Jack", "Sam"})
+	if err != nil || !reflect.DeepEqual(str(values), want) {
+		t.Fatalf("batch GetMulti = %v, %v, want %v", str(values), err, want)
+	}
+	if !reflect.DeepEqual(m.batches, [][]string{{"Jack", "Sam"}}) {
+		t.Fatalf("batches = %q, want the misses in one", m.batches)
+	}
+	if s := g.Stats(); s.Gets != 4 || s.CacheHits != 1 || s.LocalLoads != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
@@ -1,6 +1,11 @@
 // implement distrubuted nodes interact
 package go_cache
 
+import (
+	"context"
+	"time"
+)
+
 // PeerPicker must be implemented to locate
 // select a `PeerGetter` by key
 type PeerPicker interface {
@@ -12,3 +17,62 @@ type PeerPicker interface {
 type PeerGetter interface {
 	Get(group string, key string) ([]byte, error)
 }
+
+// PeerVersionedGetter is implemented by a PeerGetter returning the version,
+// expiry and flags of a value along with its bytes.
+type PeerVersionedGetter interface {
+	GetVersioned(group string, key string) (ByteView, error)
+}
+
+// PeerContextGetter is implemented by a PeerGetter able to pass the
+// deadline and trace of ctx on to the peer.
+type PeerContextGetter interface {
+	GetContext(ctx context.Context, group string, key string) (ByteView, error)
+}
+
+// PeerSetter is implemented by a PeerGetter whose node accepts writes
+// for the keys it owns.
+type PeerSetter interface {
+	Set(group string, key string, req *SetRequest) (ByteView, error)
+	// Touch changes the expiry of a cached value, reporting whether it was cached.
+	Touch(group string, key string, ttl time.Duration) (bool, error)
+}
+
+// PeerPeeker is implemented by a PeerGetter able to look a key up in the
+// cache of its node without loading it.
+type PeerPeeker interface {
+	Peek(group string, key string) (value ByteView, ok bool, err error)
+}
+
+// SetRequest is a write executed by the node owning the key.
+type SetRequest struct {
+	Value []byte
+
+	// If CheckVersion, the write fails with ErrVersionMismatch unless the
+	// cached version is Version. Version 0 requires the key is not cached.
+	CheckVersion bool
+	Version      uint64
+
+	TTL   time.Duration // overrides the group's TTL when > 0
+	Flags uint32        // stored along with the value
+}
+
+// PeerInvalidator is implemented by a PeerPicker able to tell every peer,
+// not only the owner, to drop a key from its caches.
+type PeerInvalidator interface {
+	Invalidate(group string, key string) error
+}
+
+// PeerLeaser is implemented by a PeerGetter whose node coordinates the loads
+// of the keys it owns, so that a key shed by an overloaded owner is loaded
+// by a single node of the cluster instead of every node asking for it.
+type PeerLeaser interface {
+	// Lease returns the value of key if the owner has it, fresh or stale,
+	// or else a non-zero token granting the lease to load it. Requesters
+	// wait while another node holds the lease.
+	Lease(ctx context.Context, group string, key string) (value ByteView, token uint64, err error)
+	// Fill stores the value loaded under the lease on the owner, releasing it.
+	Fill(group string, key string, token uint64, value []byte) (ByteView, error)
+	// Release gives up the lease after failing to load.
+	Release(group string, key string, token uint64) error
+}
@@ -0,0 +1,200 @@
+// Package radix implements an ordered set of strings as a radix tree,
+// for prefix scans over the keys of a cache.
+package radix
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tree is a set of strings, walked in lexical order.
+// It is not safe for concurrent access.
+type Tree struct {
+	root node
+	size int
+}
+
+type node struct {
+	prefix   string  // edge from the parent
+	leaf     bool    // the path to the node is in the set
+	children []*node // ordered by the first byte of their prefix
+}
+
+// child returns the index of the child whose prefix starts with b, or where it belongs
+func (n *node) child(b byte) (int, bool) {
+	i := sort.Search(len(n.children), func(i int) bool {
+		return n.children[i].prefix[0] >= b
+	})
+	return i, i < len(n.children) && n.children[i].prefix[0] == b
+}
+
+func (n *node) insertChild(i int, c *node) {
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = c
+}
+
+// mergeChild absorbs the only child of a node out of the set
+func (n *node) mergeChild() {
+	c := n.children[0]
+	n.prefix += c.prefix
+	n.leaf = c.leaf
+	n.children = c.children
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// Len returns the number of strings in the set
+func (t *Tree) Len() int {
+	return t.size
+}
+
+// Insert adds key, reporting whether it was missing
+func (t *Tree) Insert(key string) bool {
+	n := &t.root
+	search := key
+	for {
+		if search == "" {
+			if n.leaf {
+				return false
+			}
+			n.leaf = true
+			t.size++
+			return true
+		}
+
+		i, ok := n.child(search[0])
+		if !ok {
+			n.insertChild(i, &node{prefix: search, leaf: true})
+			t.size++
+			return true
+		}
+		c := n.children[i]
+		common := commonPrefix(search, c.prefix)
+		if common == len(c.prefix) {
+			n = c
+			search = search[common:]
+			continue
+		}
+
+		// split the edge where key leaves it
+		split := &node{prefix: c.prefix[:common], children: []*node{c}}
+		c.prefix = c.prefix[common:]
+		n.children[i] = split
+		search = search[common:]
+		if search == "" {
+			split.leaf = true
+		} else {
+			j, _ := split.child(search[0])
+			split.insertChild(j, &node{prefix: search, leaf: true})
+		}
+		t.size++
+		return true
+	}
+}
+
+// Delete removes key, reporting whether it was present
+func (t *Tree) Delete(key string) bool {
+	var parent *node
+	var index int
+	n := &t.root
+	search := key
+	for search != "" {
+		i, ok := n.child(search[0])
+		if !ok || !strings.HasPrefix(search, n.children[i].prefix) {
+			return false
+		}
+		parent, index = n, i
+		n = n.children[i]
+		search = search[len(n.prefix):]
+	}
+	if !n.leaf {
+		return false
+	}
+	n.leaf = false
+	t.size--
+
+	if parent == nil { // the empty string
+		return true
+	}
+	switch len(n.children) {
+	case 0:
+		parent.children = append(parent.children[:index], parent.children[index+1:]...)
+		if parent != &t.root && !parent.leaf && len(parent.children) == 1 {
+			parent.mergeChild()
+		}
+	case 1:
+		n.mergeChild()
+	}
+	return true
+}
+
+// Contains reports whether key is in the set
+func (t *Tree) Contains(key string) bool {
+	n := &t.root
+	search := key
+	for search != "" {
+		i, ok := n.child(search[0])
+		if !ok || !strings.HasPrefix(search, n.children[i].prefix) {
+			return false
+		}
+		n = n.children[i]
+		search = search[len(n.prefix):]
+	}
+	return n.leaf
+}
+
+// WalkPrefix calls fn in lexical order for the keys starting with prefix
+// and greater than after, until fn returns false.
+func (t *Tree) WalkPrefix(prefix string, after string, fn func(key string) bool) {
+	n := &t.root
+	path := ""
+	search := prefix
+	for search != "" {
+		i, ok := n.child(search[0])
+		if !ok {
+			return
+		}
+		c := n.children[i]
+		switch {
+		case strings.HasPrefix(search, c.prefix):
+			search = search[len(c.prefix):]
+		case strings.HasPrefix(c.prefix, search):
+			search = ""
+		default:
+			return
+		}
+		path += c.prefix
+		n = c
+	}
+	walk(n, path, after, fn)
+}
+
+// Walk calls fn in lexical order for the keys greater than after, until fn returns false.
+func (t *Tree) Walk(after string, fn func(key string) bool) {
+	walk(&t.root, "", after, fn)
+}
+
+func walk(n *node, path string, after string, fn func(key string) bool) bool {
+	// every key below is before after
+	if path < after && !strings.HasPrefix(after, path) {
+		return true
+	}
+	if n.leaf && path > after {
+		if !fn(path) {
+			return false
+		}
+	}
+	for _, c := range n.children {
+		if !walk(c, path+c.prefix, after, fn) {
+			return false
+		}
+	}
+	return true
+}
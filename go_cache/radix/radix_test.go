@@ -0,0 +1,107 @@
+package radix_test
+
+import (
+	"go_cache/radix"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func keys(t *radix.Tree, prefix, after string, limit int) []string {
+	var got []string
+	t.WalkPrefix(prefix, after, func(key string) bool {
+		got = append(got, key)
+		return limit <= 0 || len(got) < limit
+	})
+	return got
+}
+
+func TestWalkPrefix(t *testing.T) {
+	var tree radix.Tree
+	for _, key := range []string{"user:42:name", "user:42:age", "user:4", "user:420:name", "team:1", "user:42"} {
+		tree.Insert(key)
+	}
+	if tree.Insert("user:42") {
+		t.Fatal("Insert of a present key reported missing")
+	}
+
+	tests := []struct {
+		prefix string
+		after  string
+		limit  int
+		want   []string
+	}{
+		// '0' sorts before ':'
+		{"", "", 0, []string{"team:1", "user:4", "user:42", "user:420:name", "user:42:age", "user:42:name"}},
+		{"user:42:", "", 0, []string{"user:42:age", "user:42:name"}},
+		{"user:42", "", 0, []string{"user:42", "user:420:name", "user:42:age", "user:42:name"}},
+		{"user:42", "user:420:name", 0, []string{"user:42:age", "user:42:name"}},
+		{"user:4", "", 2, []string{"user:4", "user:42"}},
+		{"us", "user:42:age", 0, []string{"user:42:name"}},
+		{"user:5", "", 0, nil},
+		{"user:42:a", "", 0, []string{"user:42:age"}},
+	}
+	for _, tt := range tests {
+		if got := keys(&tree, tt.prefix, tt.after, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WalkPrefix(%q, %q) = %q, want %q", tt.prefix, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var tree radix.Tree
+	set := make(map[string]bool)
+	randKey := func() string {
+		b := make([]byte, 1+rnd.Intn(6))
+		for i := range b {
+			b[i] = "abc:"[rnd.Intn(4)]
+		}
+		return string(b)
+	}
+
+	for i := 0; i < 5000; i++ {
+		key := randKey()
+		if rnd.Intn(3) == 0 {
+			if got := tree.Delete(key); got != set[key] {
+				t.Fatalf("Delete(%q) = %v, want %v", key, got, set[key])
+			}
+			delete(set, key)
+		} else {
+			if got := tree.Insert(key); got == set[key] {
+				t.Fatalf("Insert(%q) = %v with present %v", key, got, set[key])
+			}
+			set[key] = true
+		}
+	}
+	if tree.Len() != len(set) {
+		t.Fatalf("Len() = %d, want %d", tree.Len(), len(set))
+	}
+
+	for i := 0; i < 100; i++ {
+		prefix, after := randKey(), ""
+		if len(prefix) > 2 {
+			prefix = prefix[:2]
+		}
+		if i%2 == 0 {
+			after = randKey()
+		}
+		var want []string
+		for key := range set {
+			if strings.HasPrefix(key, prefix) && key > after {
+				want = append(want, key)
+			}
+		}
+		sort.Strings(want)
+		if got := keys(&tree, prefix, after, 0); !reflect.DeepEqual(got, want) {
+			t.Fatalf("WalkPrefix(%q, %q) = %q, want %q", prefix, after, got, want)
+		}
+	}
+	for key := range set {
+		if !tree.Contains(key) {
+			t.Fatalf("Contains(%q) = false", key)
+		}
+	}
+}
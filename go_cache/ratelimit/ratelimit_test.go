@@ -0,0 +1,73 @@
+package ratelimit_test
+
+import (
+	"context"
+	"go_cache/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l := ratelimit.New(100, 3)
+	for i := 0; i < 3; i++ {
+		if !l.Allow() {
+			t.Fatalf("token %d of the burst denied", i)
+		}
+	}
+	if l.Allow() {
+		t.Fatal("expect bucket empty after burst")
+	}
+	time.Sleep(15 * time.Millisecond)
+	if !l.Allow() {
+		t.Fatal("expect a token refilled")
+	}
+}
+
+func TestRefund(t *testing.T) {
+	l := ratelimit.New(0.001, 1)
+	if !l.Allow() {
+		t.Fatal("token of the burst denied")
+	}
+	l.Refund()
+	if !l.Allow() {
+		t.Fatal("expect the refunded token taken")
+	}
+	if l.Allow() {
+		t.Fatal("expect the bucket empty")
+	}
+	l.Refund()
+	l.Refund()
+	if !l.Allow() || l.Allow() {
+		t.Fatal("expect refunds capped at the burst")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	l := ratelimit.New(0, 1)
+	for i := 0; i < 100; i++ {
+		if !l.Allow() {
+			t.Fatal("unlimited limiter denied")
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	l := ratelimit.New(200, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("5 events at 200/s took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = ratelimit.New(0.001, 1)
+	l.Allow()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait on canceled context err = %v", err)
+	}
+}
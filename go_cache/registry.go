@@ -0,0 +1,155 @@
+package go_cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds the groups of a node by name. The package level functions
+// use a default Registry, a separate one lets a process run several nodes,
+// e.g. a cluster in tests.
+type Registry struct {
+	mu     sync.RWMutex // protect groups map
+	groups map[string]*Group
+}
+
+func NewRegistry() *Registry {
+	return &Registry{groups: make(map[string]*Group)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the Registry used by the package level functions.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// NewGroup creates and registers a group, replacing any group of the same
+// name. It panics on a nil Getter and does not check the name, see
+// CreateGroup for that.
+func (r *Registry) NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+	if getter == nil {
+		panic("nil Getter")
+	}
+	g := newGroup(name, cacheBytes, getter)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groups[name] = g
+
+	return g
+}
+
+// CreateGroup creates and registers a group. The name must be unique and
+// must not start with '_', which is reserved by HTTPPool.
+func (r *Registry) CreateGroup(name string, cacheBytes int64, getter Getter) (*Group, error) {
+	if getter == nil {
+		return nil, fmt.Errorf("nil Getter")
+	}
+	if name == "" || strings.HasPrefix(name, "_") || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid group name %q", name)
+	}
+	g := newGroup(name, cacheBytes, getter)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.groups[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+	r.groups[name] = g
+
+	return g, nil
+}
+
+func newGroup(name string, cacheBytes int64, getter Getter) *Group {
+	g := &Group{
+		name:      name,
+		getter:    getter,
+		mainCache: cache{cacheBytes: cacheBytes},
+	}
+	g.cacheBytes.Store(cacheBytes)
+	return g
+}
+
+func (r *Registry) GetGroup(name string) *Group {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.groups[name]
+}
+
+// ListGroups returns the names of all groups in order.
+func (r *Registry) ListGroups() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DeleteGroup unregisters a group and releases its cached values. Later
+// operations on the group fail with ErrGroupDeleted, and peers asking
+// for it are answered not found.
+func (r *Registry) DeleteGroup(name string) bool {
+	r.mu.Lock()
+	g, ok := r.groups[name]
+	delete(r.groups, name)
+	r.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	g.deleted.Store(true)
+	g.mainCache.close()
+	g.hotCache.close()
+	g.clearDisk()
+	return true
+}
+
+// all returns the groups ordered by name
+func (r *Registry) all() []*Group {
+	var all []*Group
+	for _, name := range r.ListGroups() {
+		if g := r.GetGroup(name); g != nil {
+			all = append(all, g)
+		}
+	}
+	return all
+}
+
+// Purge drops everything cached by the groups of r
+func (r *Registry) Purge() {
+	for _, g := range r.all() {
+		g.purge()
+	}
+}
+
+// NewGroup creates and registers a group in the default Registry, see
+// Registry.NewGroup.
+func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+	return defaultRegistry.NewGroup(name, cacheBytes, getter)
+}
+
+// CreateGroup creates and registers a group in the default Registry.
+func CreateGroup(name string, cacheBytes int64, getter Getter) (*Group, error) {
+	return defaultRegistry.CreateGroup(name, cacheBytes, getter)
+}
+
+func GetGroup(name string) *Group {
+	return defaultRegistry.GetGroup(name)
+}
+
+// ListGroups returns the names of all groups in order.
+func ListGroups() []string {
+	return defaultRegistry.ListGroups()
+}
+
+// DeleteGroup unregisters a group of the default Registry, see Registry.DeleteGroup.
+func DeleteGroup(name string) bool {
+	return defaultRegistry.DeleteGroup(name)
+}
@@ -0,0 +1,298 @@
+// Package resp serves go_cache groups over the Redis protocol (RESP2).
+//
+// Supported commands are PING, ECHO, QUIT, SELECT, GET, SET, DEL, MGET,
+// EXPIRE, TTL and INFO. SELECT takes a group name, or an index into the
+// groups sorted by name so that clients sending "SELECT 0" keep working.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go_cache"
+	"go_cache/internal/tcpserver"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server is a RESP2 frontend of go_cache groups.
+type Server struct {
+	defaultGroup string // group of new connections
+	srv          *tcpserver.Server
+}
+
+// NewServer creates a Server whose connections start on defaultGroup.
+func NewServer(defaultGroup string) *Server {
+	s := &Server{defaultGroup: defaultGroup}
+	s.srv = tcpserver.New(s.serveConn)
+	return s
+}
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = tcpserver.ErrServerClosed
+
+func (s *Server) ListenAndServe(addr string) error {
+	return s.srv.ListenAndServe(addr)
+}
+
+// Serve accepts connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	return s.srv.Serve(l)
+}
+
+// Close stops the listeners and closes all connections.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// session is the state of a connection
+type session struct {
+	group string
+	w     writer
+	quit  bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	sess := &session{group: s.defaultGroup, w: writer{bufio.NewWriter(conn)}}
+	for !sess.quit {
+		// flush once the pipelined commands are all answered
+		if r.Buffered() == 0 {
+			if err := sess.w.Flush(); err != nil {
+				return
+			}
+		}
+
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				sess.w.error("ERR " + err.Error())
+				sess.w.Flush()
+			} else if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				slog.Info("[RESP] read command failed", "remote", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		sess.exec(args)
+	}
+	sess.w.Flush()
+}
+
+// arity is the number of arguments of commands, including the name.
+// A negative value means at least that many.
+var arity = map[string]int{
+	"PING": -1, "ECHO": 2, "QUIT": 1, "SELECT": 2, "COMMAND": -1,
+	"GET": 2, "SET": -3, "DEL": -2, "MGET": -2, "EXPIRE": 3, "TTL": 2, "INFO": -1,
+}
+
+func (sess *session) exec(args [][]byte) {
+	w := sess.w
+	name := strings.ToUpper(string(args[0]))
+	n, ok := arity[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if (n > 0 && len(args) != n) || (n < 0 && len(args) < -n) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			w.bulk(args[1])
+		} else {
+			w.simple("PONG")
+		}
+		return
+	case "ECHO":
+		w.bulk(args[1])
+		return
+	case "QUIT":
+		w.simple("OK")
+		sess.quit = true
+		return
+	case "COMMAND":
+		w.array(0)
+		return
+	case "SELECT":
+		sess.selectGroup(string(args[1]))
+		return
+	case "INFO":
+		sess.info()
+		return
+	}
+
+	g := go_cache.GetGroup(sess.group)
+	if g == nil {
+		w.error("ERR no such group '" + sess.group + "', use SELECT <group>")
+		return
+	}
+
+	switch name {
+	case "GET":
+		view, err := g.Get(string(args[1]))
+		if isNotFound(err) {
+			w.null()
+		} else if err != nil {
+			w.error("ERR " + err.Error())
+		} else {
+			w.bulk(view.ByteSlice())
+		}
+
+	case "MGET":
+		w.array(len(args) - 1)
+		for _, key := range args[1:] {
+			if view, err := g.Get(string(key)); err != nil {
+				w.null()
+			} else {
+				w.bulk(view.ByteSlice())
+			}
+		}
+
+	case "SET":
+		sess.set(g, args)
+
+	case "DEL":
+		var n int64
+		for _, key := range args[1:] {
+			if deleted, err := g.Delete(string(key)); err == nil && deleted {
+				n++
+			}
+		}
+		w.integer(n)
+
+	case "EXPIRE":
+		seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+		key := string(args[1])
+		if seconds <= 0 {
+			if deleted, err := g.Delete(key); err != nil {
+				w.error("ERR " + err.Error())
+			} else if deleted {
+				w.integer(1)
+			} else {
+				w.integer(0)
+			}
+			return
+		}
+		ok, err := g.Expire(key, time.Duration(seconds)*time.Second)
+		if err != nil {
+			w.error("ERR " + err.Error())
+		} else if ok {
+			w.integer(1)
+		} else {
+			w.integer(0)
+		}
+
+	case "TTL":
+		// a key not cached is not loaded to report it
+		view, ok, err := g.Peek(string(args[1]))
+		switch {
+		case err != nil:
+			w.error("ERR " + err.Error())
+		case !ok:
+			w.integer(-2)
+		case view.Expire().IsZero():
+			w.integer(-1)
+		default:
+			w.integer(int64(math.Ceil(time.Until(view.Expire()).Seconds())))
+		}
+	}
+}
+
+// set handles "SET key value [EX seconds|PX milliseconds] [NX]"
+func (sess *session) set(g *go_cache.Group, args [][]byte) {
+	w := sess.w
+	var ttl time.Duration
+	var nx bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "NX":
+			nx = true
+		case "EX", "PX":
+			if i+1 == len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			ttl = time.Duration(n) * time.Millisecond
+			if opt == "EX" {
+				ttl = time.Duration(n) * time.Second
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	// version 0 means the key must not be cached
+	req := &go_cache.SetRequest{Value: args[2], TTL: ttl, CheckVersion: nx}
+	if _, err := g.Store(string(args[1]), req); errors.Is(err, go_cache.ErrVersionMismatch) {
+		w.null()
+		return
+	} else if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.simple("OK")
+}
+
+func (sess *session) selectGroup(arg string) {
+	groups := go_cache.ListGroups()
+	if i, err := strconv.Atoi(arg); err == nil && go_cache.GetGroup(arg) == nil {
+		if i < 0 || i >= len(groups) {
+			sess.w.error("ERR DB index is out of range")
+			return
+		}
+		arg = groups[i]
+	}
+	if go_cache.GetGroup(arg) == nil {
+		sess.w.error("ERR no such group '" + arg + "'")
+		return
+	}
+	sess.group = arg
+	sess.w.simple("OK")
+}
+
+// info writes the usage and counters of every group in INFO format
+func (sess *session) info() {
+	var b strings.Builder
+	b.WriteString("# Server\r\n")
+	b.WriteString("go_cache_group:" + sess.group + "\r\n")
+	for _, name := range go_cache.ListGroups() {
+		g := go_cache.GetGroup(name)
+		if g == nil {
+			continue
+		}
+		info, s := g.Info(), g.Stats()
+		fmt.Fprintf(&b, "\r\n# Group %s\r\n", name)
+		fmt.Fprintf(&b, "items:%d\r\nbytes:%d\r\ncache_bytes:%d\r\nttl:%s\r\n",
+			info.Items, info.Bytes, info.CacheBytes, info.TTL)
+		fmt.Fprintf(&b, "gets:%d\r\ncache_hits:%d\r\nloads:%d\r\npeer_loads:%d\r\nlocal_loads:%d\r\nlocal_load_errs:%d\r\n",
+			s.Gets, s.CacheHits, s.Loads, s.PeerLoads, s.LocalLoads, s.LocalLoadErrs)
+	}
+	sess.w.bulk([]byte(b.String()))
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, go_cache.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
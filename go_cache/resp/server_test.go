@@ -0,0 +1,184 @@
+package resp_test
+
+import (
+	"bufio"
+	"fmt"
+	"go_cache"
+	"go_cache/resp"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// client is a minimal RESP2 client
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do sends a command and returns its reply in a readable form:
+// "+OK", "-ERR ...", ":1", "(nil)", a bulk string, or "[a b]" for arrays
+func (c *client) do(t *testing.T, args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := c.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func (c *client) read() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', '-', ':':
+		return line, nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		items := make([]string, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return "", err
+			}
+		}
+		return "[" + strings.Join(items, " ") + "]", nil
+	}
+	return "", fmt.Errorf("bad reply %q", line)
+}
+
+func TestServer(t *testing.T) {
+	go_cache.NewGroup("resp-scores", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "Tom" {
+				return []byte("630"), nil
+			}
+			return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+		}))
+	go_cache.NewGroup("resp-other", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("other"), nil
+		}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := resp.NewServer("resp-scores")
+	go srv.Serve(l)
+	defer srv.Close()
+
+	c := dial(t, l.Addr().String())
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"TTL", "Tom"}, ":-2"}, // not loaded to answer
+		{[]string{"get", "Tom"}, "630"},
+		{[]string{"GET", "Sam"}, "(nil)"},
+		{[]string{"TTL", "Sam"}, ":-2"},
+		{[]string{"TTL", "Tom"}, ":-1"},
+		{[]string{"SET", "Sam", "567", "EX", "100"}, "+OK"},
+		{[]string{"TTL", "Sam"}, ":100"},
+		{[]string{"SET", "Sam", "568", "NX"}, "(nil)"},
+		{[]string{"SET", "Jack", "589", "NX", "PX", "5000"}, "+OK"},
+		{[]string{"TTL", "Jack"}, ":5"},
+		{[]string{"EXPIRE", "Tom", "10"}, ":1"},
+		{[]string{"TTL", "Tom"}, ":10"},
+		{[]string{"EXPIRE", "Nobody", "10"}, ":0"},
+		{[]string{"MGET", "Tom", "Sam", "Nobody"}, "[630 567 (nil)]"},
+		{[]string{"DEL", "Sam", "Jack"}, ":2"},
+		{[]string{"DEL", "Sam", "Nobody"}, ":0"},
+		{[]string{"GET", "Sam"}, "(nil)"},
+		{[]string{"EXPIRE", "Nobody", "0"}, ":0"},
+		{[]string{"EXPIRE", "Tom", "-1"}, ":1"},
+		{[]string{"TTL", "Tom"}, ":-2"},
+		{[]string{"SET", "Sam"}, "-ERR wrong number of arguments for 'set' command"},
+		{[]string{"SET", "Sam", "1", "EX", "0"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"FLUSHALL"}, "-ERR unknown command 'FLUSHALL'"},
+		{[]string{"SELECT", "resp-other"}, "+OK"},
+		{[]string{"GET", "Tom"}, "other"},
+		{[]string{"SELECT", "missing"}, "-ERR no such group 'missing'"},
+		{[]string{"QUIT"}, "+OK"},
+	}
+	for _, tt := range tests {
+		if got := c.do(t, tt.args...); got != tt.want {
+			t.Fatalf("%v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if _, err := c.read(); err != io.EOF {
+		t.Fatalf("expect connection closed after QUIT, err = %v", err)
+	}
+}
+
+func TestInlineAndPipeline(t *testing.T) {
+	go_cache.NewGroup("resp-inline", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := resp.NewServer("resp-inline")
+	go srv.Serve(l)
+	defer srv.Close()
+
+	c := dial(t, l.Addr().String())
+	io.WriteString(c.conn, "GET a\r\nGET b\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n")
+	for _, want := range []string{"a", "b", "hi"} {
+		if got, err := c.read(); err != nil || got != want {
+			t.Fatalf("reply = %q, %v, want %q", got, err, want)
+		}
+	}
+	c.conn.Write([]byte("INFO\r\n"))
+	if got, err := c.read(); err != nil || !strings.Contains(got, "# Group resp-inline") {
+		t.Fatalf("INFO = %q, %v", got, err)
+	}
+
+	// bulk strings are read in chunks
+	big := strings.Repeat("0123456789", 20000)
+	if got := c.do(t, "SET", "big", big); got != "+OK" {
+		t.Fatalf("SET big = %q", got)
+	}
+	if got := c.do(t, "GET", "big"); got != big {
+		t.Fatalf("GET big = %d bytes, want %d", len(got), len(big))
+	}
+	c.conn.Write([]byte("*2\r\n$3\r\nGET\r\n$536870912\r\nbig\r\n"))
+	c.conn.(*net.TCPConn).CloseWrite()
+	if _, err := c.read(); err != io.EOF {
+		t.Fatalf("expect connection closed on a truncated bulk string, err = %v", err)
+	}
+}
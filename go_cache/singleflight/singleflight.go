@@ -0,0 +1,86 @@
+package singleflight
+
+import "sync"
+
+// call is an in-flight or completed Do call
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+
+	chans []chan<- Result // of the DoChan callers waiting
+}
+
+// Result is the outcome of a DoChan call. Shared reports whether it was
+// got by waiting for the call of another caller.
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
+}
+
+// Group coalesces concurrent calls sharing a key, so that fn runs only once
+// while the others wait for its result.
+type Group struct {
+	mu sync.Mutex // protects m
+	m  map[string]*call
+}
+
+// Do executes fn and returns its result. If a call for key is already in
+// flight, Do waits for it and returns the same result instead.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	return c.val, c.err
+}
+
+// DoChan is like Do but returns a channel receiving the result, so that
+// the caller may stop waiting while fn runs on for the others.
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go func() {
+		g.doCall(c, key, fn)
+		ch <- Result{Val: c.val, Err: c.err}
+	}()
+	return ch
+}
+
+// doCall runs fn for the call c and hands its result to the waiters
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.m, key)
+	for _, ch := range c.chans {
+		ch <- Result{Val: c.val, Err: c.err, Shared: true}
+	}
+	g.mu.Unlock()
+}
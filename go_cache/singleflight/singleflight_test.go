@@ -0,0 +1,73 @@
+package singleflight_test
+
+import (
+	"go_cache/singleflight"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g singleflight.Group
+	v, err := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil {
+		t.Fatalf("Do = %v, %v", v, err)
+	}
+}
+
+func TestDoDupSuppress(t *testing.T) {
+	var g singleflight.Group
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.Do("key", fn); v != "bar" || err != nil {
+				t.Errorf("Do = %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // let the goroutines block in Do
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("fn called %d times, want 1", got)
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var g singleflight.Group
+	started, release := make(chan struct{}), make(chan struct{})
+	first := g.DoChan("key", func() (interface{}, error) {
+		close(started)
+		<-release
+		return "bar", nil
+	})
+	<-started
+	second := g.DoChan("key", func() (interface{}, error) {
+		t.Error("fn of a coalesced call run")
+		return nil, nil
+	})
+
+	// the first caller may give up without holding the second back
+	close(release)
+	if res := <-second; res.Val != "bar" || res.Err != nil || !res.Shared {
+		t.Fatalf("second DoChan = %+v, want shared bar", res)
+	}
+	if res := <-first; res.Val != "bar" || res.Err != nil || res.Shared {
+		t.Fatalf("first DoChan = %+v, want bar", res)
+	}
+}
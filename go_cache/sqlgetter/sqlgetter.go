@@ -0,0 +1,195 @@
+// Package sqlgetter builds go_cache Getters from SQL queries, so that a
+// group caches rows of a database aside of it.
+//
+//	getter, err := sqlgetter.New(db, sqlgetter.Config{
+//		Query:      "SELECT name, score FROM scores WHERE name = ?",
+//		BatchQuery: "SELECT name, score FROM scores WHERE name IN (%s)",
+//	})
+//	group := go_cache.NewGroup("scores", 2<<10, getter)
+package sqlgetter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go_cache"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxBatch = 100
+	defaultTimeout  = 5 * time.Second
+)
+
+// RowEncoder reads the current row of rows into the key it belongs to and
+// the value cached for it.
+type RowEncoder func(rows *sql.Rows) (key string, value []byte, err error)
+
+// Config of a Getter
+type Config struct {
+	// Query selects the row of a key given as its only argument, e.g.
+	// "SELECT name, score FROM scores WHERE name = ?".
+	Query string
+	// BatchQuery selects the rows of several keys, "%s" is replaced by one
+	// placeholder per key, e.g. "SELECT name, score FROM scores WHERE name IN (%s)".
+	// Empty makes GetMulti run Query for every key.
+	BatchQuery string
+	// Placeholder returns the i-th placeholder of BatchQuery from 1,
+	// "?" by default. e.g. "$1" for PostgreSQL.
+	Placeholder func(i int) string
+	// MaxBatch bounds the keys of a BatchQuery, 100 by default.
+	MaxBatch int
+	// Timeout of a query, 5s by default.
+	Timeout time.Duration
+	// Encode reads a row, KeyValue by default.
+	Encode RowEncoder
+}
+
+// Getter loads keys by SQL queries. It implements go_cache.MultiGetter.
+type Getter struct {
+	db   *sql.DB
+	conf Config
+}
+
+var _ go_cache.MultiGetter = (*Getter)(nil)
+
+func New(db *sql.DB, conf Config) (*Getter, error) {
+	if conf.Query == "" {
+		return nil, fmt.Errorf("sqlgetter: Query is required")
+	}
+	if conf.BatchQuery != "" && strings.Count(conf.BatchQuery, "%s") != 1 {
+		return nil, fmt.Errorf("sqlgetter: BatchQuery must contain %%s once")
+	}
+	if conf.Placeholder == nil {
+		conf.Placeholder = func(int) string { return "?" }
+	}
+	if conf.MaxBatch <= 0 {
+		conf.MaxBatch = defaultMaxBatch
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+	if conf.Encode == nil {
+		conf.Encode = KeyValue
+	}
+	return &Getter{db: db, conf: conf}, nil
+}
+
+// Get runs Query for key, failing with go_cache.ErrNotFound when no row is selected
+func (g *Getter) Get(key string) ([]byte, error) {
+	values, err := g.query(g.conf.Query, []any{key})
+	if err != nil {
+		return nil, err
+	}
+	value, ok := values[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+	}
+	return value, nil
+}
+
+// GetMulti runs BatchQuery for up to MaxBatch keys at a time
+func (g *Getter) GetMulti(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if g.conf.BatchQuery == "" {
+		for _, key := range keys {
+			found, err := g.query(g.conf.Query, []any{key})
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := found[key]; ok {
+				values[key] = v
+			}
+		}
+		return values, nil
+	}
+
+	for len(keys) > 0 {
+		n := min(len(keys), g.conf.MaxBatch)
+		placeholders := make([]string, n)
+		args := make([]any, n)
+		for i, key := range keys[:n] {
+			placeholders[i] = g.conf.Placeholder(i + 1)
+			args[i] = key
+		}
+		query := fmt.Sprintf(g.conf.BatchQuery, strings.Join(placeholders, ", "))
+		found, err := g.query(query, args)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range found {
+			values[key] = v
+		}
+		keys = keys[n:]
+	}
+	return values, nil
+}
+
+// query returns the values of the selected rows by key, the first row of a key wins
+func (g *Getter) query(query string, args []any) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.conf.Timeout)
+	defer cancel()
+
+	rows, err := g.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string][]byte)
+	for rows.Next() {
+		key, value, err := g.conf.Encode(rows)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := values[key]; !ok {
+			values[key] = value
+		}
+	}
+	return values, rows.Err()
+}
+
+// KeyValue reads a row of two columns, the key and the value.
+func KeyValue(rows *sql.Rows) (string, []byte, error) {
+	var key string
+	var value []byte
+	if err := rows.Scan(&key, &value); err != nil {
+		return "", nil, err
+	}
+	return key, value, nil
+}
+
+// JSONColumns reads a row whose first column is the key into a JSON object
+// of the other columns by name.
+func JSONColumns(rows *sql.Rows) (string, []byte, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(columns) < 2 {
+		return "", nil, fmt.Errorf("sqlgetter: expect a key and value columns, got %d columns", len(columns))
+	}
+
+	var key string
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	dest[0] = &key
+	for i := 1; i < len(columns); i++ {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", nil, err
+	}
+
+	object := make(map[string]any, len(columns)-1)
+	for i := 1; i < len(columns); i++ {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		object[columns[i]] = values[i]
+	}
+	value, err := json.Marshal(object)
+	return key, value, err
+}
@@ -0,0 +1,193 @@
+package sqlgetter_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"go_cache"
+	"go_cache/sqlgetter"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// stubDriver serves the table "scores" (name, score, team) to any query,
+// selecting the rows whose name is one of the arguments.
+type stubDriver struct {
+	mu      sync.Mutex
+	rows    map[string][2]any
+	queries []string
+}
+
+var stub = &stubDriver{rows: map[string][2]any{
+	"Tom":  {int64(630), "red"},
+	"Jack": {int64(589), "blue"},
+	"Sam":  {int64(567), nil},
+}}
+
+func init() {
+	sql.Register("stub", stub)
+}
+
+func (d *stubDriver) Open(name string) (driver.Conn, error) { return stubConn{d}, nil }
+
+// takeQueries returns the queries run since the last call
+func (d *stubDriver) takeQueries() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q := d.queries
+	d.queries = nil
+	return q
+}
+
+type stubConn struct{ d *stubDriver }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c.d, query}, nil }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type stubStmt struct {
+	d     *stubDriver
+	query string
+}
+
+func (s stubStmt) Close() error  { return nil }
+func (s stubStmt) NumInput() int { return -1 }
+func (s stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+
+	rows := &stubRows{}
+	for _, arg := range args {
+		name := arg.(string)
+		if row, ok := s.d.rows[name]; ok {
+			rows.values = append(rows.values, []driver.Value{name, row[0], row[1]})
+		}
+	}
+	return rows, nil
+}
+
+type stubRows struct {
+	values [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return []string{"name", "score", "team"} }
+func (r *stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func openStub(t *testing.T) *sql.DB {
+	db, err := sql.Open("stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	stub.takeQueries()
+	return db
+}
+
+func TestGet(t *testing.T) {
+	getter, err := sqlgetter.New(openStub(t), sqlgetter.Config{
+		Query:  "SELECT name, score, team FROM scores WHERE name = ?",
+		Encode: sqlgetter.JSONColumns,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key   string
+		value string
+		err   error
+	}{
+		{"Tom", `{"score":630,"team":"red"}`, nil},
+		{"Sam", `{"score":567,"team":null}`, nil},
+		{"Ann", "", go_cache.ErrNotFound},
+	}
+	for _, tt := range tests {
+		v, err := getter.Get(tt.key)
+		if string(v) != tt.value || !errors.Is(err, tt.err) {
+			t.Errorf("Get(%s) = %s, %v, want %s, %v", tt.key, v, err, tt.value, tt.err)
+		}
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	db := openStub(t)
+	scoreOnly := func(rows *sql.Rows) (string, []byte, error) {
+		var name, team sql.NullString
+		var score int64
+		err := rows.Scan(&name, &score, &team)
+		return name.String, []byte(fmt.Sprint(score)), err
+	}
+	getter, err := sqlgetter.New(db, sqlgetter.Config{
+		Query:       "SELECT name, score, team FROM scores WHERE name = $1",
+		BatchQuery:  "SELECT name, score, team FROM scores WHERE name IN (%s)",
+		Placeholder: func(i int) string { return fmt.Sprintf("$%d", i) },
+		MaxBatch:    2,
+		Encode:      scoreOnly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := getter.GetMulti([]string{"Tom", "Ann", "Jack", "Sam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"Tom": []byte("630"), "Jack": []byte("589"), "Sam": []byte("567")}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("GetMulti = %q, want %q", values, want)
+	}
+	wantQueries := []string{
+		"SELECT name, score, team FROM scores WHERE name IN ($1, $2)",
+		"SELECT name, score, team FROM scores WHERE name IN ($1, $2)",
+	}
+	if q := stub.takeQueries(); !reflect.DeepEqual(q, wantQueries) {
+		t.Fatalf("queries = %q, want %q", q, wantQueries)
+	}
+
+	// through a group, cached keys are not queried again
+	g := go_cache.NewGroup("sqlgetter", 0, getter)
+	g.Get("Tom")
+	stub.takeQueries()
+	views, err := g.GetMulti([]string{"Tom", "Jack", "Sam", "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for key, v := range views {
+		got = append(got, key+"="+v.String())
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"Jack=589", "Sam=567", "Tom=630"}) {
+		t.Fatalf("Group.GetMulti = %q", got)
+	}
+	if q := stub.takeQueries(); len(q) != 2 || q[0] != wantQueries[0] || q[1] != "SELECT name, score, team FROM scores WHERE name IN ($1)" {
+		t.Fatalf("queries = %q, want batches of Jack, Sam and Ann", q)
+	}
+}
+
+func TestNew(t *testing.T) {
+	db := openStub(t)
+	if _, err := sqlgetter.New(db, sqlgetter.Config{}); err == nil {
+		t.Fatal("expect an error without Query")
+	}
+	if _, err := sqlgetter.New(db, sqlgetter.Config{Query: "q", BatchQuery: "SELECT * FROM t"}); err == nil {
+		t.Fatal("expect an error without a placeholder list in BatchQuery")
+	}
+}
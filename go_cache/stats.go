@@ -0,0 +1,103 @@
+package go_cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// groupStats are counters of a group, updated atomically
+type groupStats struct {
+	gets           atomic.Int64 // any Get request, including from peers
+	cacheHits      atomic.Int64
+	staleHits      atomic.Int64 // expired values served because reloading failed
+	diskHits       atomic.Int64 // values moved back from the disk tier
+	hotHits        atomic.Int64 // replicas of hot keys owned by peers
+	diskWrites     atomic.Int64 // evicted values written to the disk tier
+	loads          atomic.Int64 // gets - cacheHits - hotHits - diskHits
+	loadsDeduped   atomic.Int64 // loads after singleflight
+	peerLoads      atomic.Int64 // values got from peers
+	peerErrors     atomic.Int64
+	peerRejects    atomic.Int64 // gets shed by overloaded peers
+	localLoads     atomic.Int64 // values got from the Getter
+	localLoadErrs  atomic.Int64
+	loadsShed      atomic.Int64 // loads refused over the bound of concurrent loads
+	serverRequests atomic.Int64 // gets that came over the network from peers
+	leasesGranted  atomic.Int64 // load leases granted to peers
+	leaseWaits     atomic.Int64 // lease requests that waited for the holder
+
+	loadLatency histogram // of the Getter
+	peerLatency histogram // of gets from peers, including failed ones
+}
+
+// Stats is a snapshot of the counters of a group
+type Stats struct {
+	Gets           int64 `json:"gets"`
+	CacheHits      int64 `json:"cache_hits"`
+	StaleHits      int64 `json:"stale_hits"`
+	DiskHits       int64 `json:"disk_hits"`
+	HotHits        int64 `json:"hot_hits"`
+	DiskWrites     int64 `json:"disk_writes"`
+	Loads          int64 `json:"loads"`
+	LoadsDeduped   int64 `json:"loads_deduped"`
+	PeerLoads      int64 `json:"peer_loads"`
+	PeerErrors     int64 `json:"peer_errors"`
+	PeerRejects    int64 `json:"peer_rejects"`
+	LocalLoads     int64 `json:"local_loads"`
+	LocalLoadErrs  int64 `json:"local_load_errs"`
+	LoadsShed      int64 `json:"loads_shed"`
+	ServerRequests int64 `json:"server_requests"`
+	LeasesGranted  int64 `json:"leases_granted"`
+	LeaseWaits     int64 `json:"lease_waits"`
+	Evictions      int64 `json:"evictions"`
+	Expirations    int64 `json:"expirations"`
+}
+
+// Stats returns a snapshot of the group's counters
+func (g *Group) Stats() Stats {
+	s := &g.stats
+	return Stats{
+		Gets:           s.gets.Load(),
+		CacheHits:      s.cacheHits.Load(),
+		StaleHits:      s.staleHits.Load(),
+		DiskHits:       s.diskHits.Load(),
+		HotHits:        s.hotHits.Load(),
+		DiskWrites:     s.diskWrites.Load(),
+		Loads:          s.loads.Load(),
+		LoadsDeduped:   s.loadsDeduped.Load(),
+		PeerLoads:      s.peerLoads.Load(),
+		PeerErrors:     s.peerErrors.Load(),
+		PeerRejects:    s.peerRejects.Load(),
+		LocalLoads:     s.localLoads.Load(),
+		LocalLoadErrs:  s.localLoadErrs.Load(),
+		LoadsShed:      s.loadsShed.Load(),
+		ServerRequests: s.serverRequests.Load(),
+		LeasesGranted:  s.leasesGranted.Load(),
+		LeaseWaits:     s.leaseWaits.Load(),
+		Evictions:      g.mainCache.evictions.Load(),
+		Expirations:    g.mainCache.expirations.Load(),
+	}
+}
+
+// latencyBuckets are the upper bounds in seconds of the latency histograms
+var latencyBuckets = [...]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram counts durations into latencyBuckets, the zero value is ready to use
+type histogram struct {
+	counts [len(latencyBuckets) + 1]atomic.Int64 // the last one is +Inf
+	sum    atomic.Int64                          // nanoseconds
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := 0
+	for i < len(latencyBuckets) && seconds > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// since observes the time elapsed since start
+func (h *histogram) since(start time.Time) {
+	h.observe(time.Since(start))
+}
@@ -0,0 +1,716 @@
+package go_cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go_cache/consistenthash"
+	"go_cache/internal/tcpserver"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A TCPPool exchanges values with its peers over persistent TCP connections.
+// Every frame carries a request id, so many requests are pipelined on one
+// connection and answered out of order as soon as they complete.
+//
+// Frames are "length uint32 | id uint64 | op or status uint8 | payload",
+// integers in big endian. Invalidations are not broadcast, Group.Invalidate
+// only drops the key on this node.
+
+const (
+	defaultTCPConns       = 2
+	defaultTCPDialTimeout = 3 * time.Second
+	defaultTCPTimeout     = 5 * time.Second
+	defaultTCPKeepAlive   = 30 * time.Second
+
+	maxFrameSize   = 64 << 20 // larger frames are a protocol error
+	maxPayloadSize = maxFrameSize - 8 - 1
+	maxTCPInflight = 128 // requests executed concurrently per connection
+	frameHeaderLen = 4 + 8 + 1
+)
+
+// request operations
+const (
+	opGet byte = iota + 1
+	opSet
+	opTouch
+	opPing
+	opPeek
+)
+
+// response statuses
+const (
+	statusOK byte = iota
+	statusNotFound
+	statusVersionMismatch
+	statusError
+	statusOverloaded
+)
+
+var errFrameTooLarge = errors.New("frame too large")
+
+// TCPPoolOptions tunes the client side of a TCPPool. Zero fields use defaults.
+type TCPPoolOptions struct {
+	Conns       int           // connections per peer, default 2
+	DialTimeout time.Duration // default 3s
+	Timeout     time.Duration // of a request, default 5s
+	KeepAlive   time.Duration // idle time before a connection is pinged, default 30s
+}
+
+type TCPPool struct {
+	self     string // address of this node, as listed in Set
+	opts     TCPPoolOptions
+	srv      *tcpserver.Server
+	registry *Registry
+
+	mu       sync.Mutex // guards later five variables
+	peers    *consistenthash.Map
+	replicas int                 // virtual nodes of a peer in peers
+	hash     consistenthash.Hash // of keys and virtual nodes, nil means crc32
+	peerList []string
+	getters  map[string]*tcpGetter // keyed by peer address, e.g. "10.0.0.2:8009"
+}
+
+// NewTCPPool creates a pool for the node listening on self. opts may be nil.
+func NewTCPPool(self string, opts *TCPPoolOptions) *TCPPool {
+	p := &TCPPool{self: self, registry: defaultRegistry, replicas: DefaultReplicas}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Conns <= 0 {
+		p.opts.Conns = defaultTCPConns
+	}
+	if p.opts.DialTimeout <= 0 {
+		p.opts.DialTimeout = defaultTCPDialTimeout
+	}
+	if p.opts.Timeout <= 0 {
+		p.opts.Timeout = defaultTCPTimeout
+	}
+	if p.opts.KeepAlive <= 0 {
+		p.opts.KeepAlive = defaultTCPKeepAlive
+	}
+	p.srv = tcpserver.New(p.serveConn)
+	return p
+}
+
+// SetRegistry makes the pool serve the groups of r instead of the default
+// Registry. It must be called before serving.
+func (p *TCPPool) SetRegistry(r *Registry) {
+	p.registry = r
+}
+
+// SetHash makes keys owned by peers hashed by fn, with replicas virtual
+// nodes each, like HTTPPool.SetHash. It must be called before Set.
+func (p *TCPPool) SetHash(replicas int, fn consistenthash.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	p.replicas = replicas
+	p.hash = fn
+}
+
+// Set updates the pool's list of peers. Connections to the peers that were
+// removed are closed.
+func (p *TCPPool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.peers = consistenthash.New(p.replicas, p.hash)
+	p.peers.Add(peers...)
+	p.peerList = append([]string(nil), peers...)
+
+	getters := make(map[string]*tcpGetter, len(peers))
+	for _, peer := range peers {
+		if g, ok := p.getters[peer]; ok {
+			getters[peer] = g
+			delete(p.getters, peer)
+		} else {
+			getters[peer] = &tcpGetter{addr: peer, opts: &p.opts, conns: make([]*tcpConn, p.opts.Conns)}
+		}
+	}
+	for _, g := range p.getters {
+		g.close()
+	}
+	p.getters = getters
+}
+
+// Peers returns the peers set by Set.
+func (p *TCPPool) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.peerList...)
+}
+
+// PickPeer picks a peer according key
+func (p *TCPPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.peers == nil {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+		return p.getters[peer], true
+	}
+	return nil, false
+}
+
+var _ PeerPicker = (*TCPPool)(nil)
+
+func (p *TCPPool) ListenAndServe(addr string) error {
+	return p.srv.ListenAndServe(addr)
+}
+
+// Serve answers peers connecting on l until Close is called.
+func (p *TCPPool) Serve(l net.Listener) error {
+	return p.srv.Serve(l)
+}
+
+// Close stops serving and closes the connections to the peers.
+func (p *TCPPool) Close() error {
+	p.mu.Lock()
+	for _, g := range p.getters {
+		g.close()
+	}
+	p.getters = nil
+	p.peers = nil
+	p.peerList = nil
+	p.mu.Unlock()
+
+	return p.srv.Close()
+}
+
+// ********************** server end *************************
+
+// serveConn reads the requests of a peer and answers each of them
+// in its own goroutine.
+func (p *TCPPool) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := &frameWriter{conn: conn, w: bufio.NewWriter(conn)}
+	sem := make(chan struct{}, maxTCPInflight)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		id, op, payload, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				slog.Info(fmt.Sprintf("[Server %s] read request failed", p.self), "remote", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			status, body := p.handle(op, payload)
+			if len(body) > maxPayloadSize {
+				status, body = statusError, []byte(errFrameTooLarge.Error())
+			}
+			if err := w.write(id, status, body, time.Time{}); err != nil {
+				conn.Close()
+			}
+		}()
+	}
+}
+
+func (p *TCPPool) handle(op byte, payload []byte) (status byte, body []byte) {
+	if op == opPing {
+		return statusOK, nil
+	}
+
+	d := decoder{b: payload}
+	groupName, key := d.string(), d.string()
+	if d.err != nil {
+		return statusError, []byte(d.err.Error())
+	}
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		return statusError, []byte("no such group: " + groupName)
+	}
+
+	switch op {
+	case opGet:
+		group.stats.serverRequests.Add(1)
+		view, err := group.Get(key)
+		if errors.Is(err, ErrOverloaded) {
+			return statusOverloaded, []byte(err.Error())
+		} else if err != nil {
+			return statusError, []byte(err.Error())
+		}
+		return statusOK, appendValue(nil, view)
+
+	case opSet:
+		req := &SetRequest{
+			CheckVersion: d.uint8() != 0,
+			Version:      d.uint64(),
+			TTL:          time.Duration(d.uint64()),
+			Flags:        d.uint32(),
+		}
+		req.Value = d.rest()
+		if d.err != nil {
+			return statusError, []byte(d.err.Error())
+		}
+		// the caller picked this node as owner, like a forwarded HTTP write
+		view, err := group.setLocally(key, req)
+		if errors.Is(err, ErrVersionMismatch) {
+			return statusVersionMismatch, nil
+		} else if err != nil {
+			return statusError, []byte(err.Error())
+		}
+		return statusOK, appendValue(nil, view)
+
+	case opTouch:
+		ttl := time.Duration(d.uint64())
+		if d.err != nil {
+			return statusError, []byte(d.err.Error())
+		}
+		if !group.expireLocally(key, ttl) {
+			return statusNotFound, nil
+		}
+		return statusOK, nil
+
+	case opPeek:
+		view, ok := group.peekLocally(key)
+		if !ok {
+			return statusNotFound, nil
+		}
+		return statusOK, appendValue(nil, view)
+	}
+	return statusError, []byte(fmt.Sprintf("unknown operation %d", op))
+}
+
+// ********************** client end *************************
+
+// tcpGetter spreads the requests to a peer over a few connections,
+// dialing them again once broken.
+type tcpGetter struct {
+	addr string
+	opts *TCPPoolOptions
+	next atomic.Uint32 // round robin over conns
+
+	mu     sync.Mutex
+	conns  []*tcpConn
+	closed bool
+}
+
+func (t *tcpGetter) Get(group string, key string) ([]byte, error) {
+	view, err := t.GetVersioned(group, key)
+	return view.b, err
+}
+
+func (t *tcpGetter) GetVersioned(group string, key string) (ByteView, error) {
+	status, body, err := t.call(opGet, appendKey(nil, group, key))
+	if err != nil {
+		return ByteView{}, err
+	}
+	if status != statusOK {
+		return ByteView{}, responseError(status, body)
+	}
+	return decodeValue(body)
+}
+
+func (t *tcpGetter) Set(group string, key string, req *SetRequest) (ByteView, error) {
+	payload := appendKey(make([]byte, 0, 64+len(group)+len(key)+len(req.Value)), group, key)
+	var check byte
+	if req.CheckVersion {
+		check = 1
+	}
+	payload = append(payload, check)
+	payload = binary.BigEndian.AppendUint64(payload, req.Version)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(req.TTL))
+	payload = binary.BigEndian.AppendUint32(payload, req.Flags)
+	payload = append(payload, req.Value...)
+
+	status, body, err := t.call(opSet, payload)
+	if err != nil {
+		return ByteView{}, err
+	}
+	switch status {
+	case statusOK:
+		return decodeValue(body)
+	case statusVersionMismatch:
+		return ByteView{}, ErrVersionMismatch
+	}
+	return ByteView{}, responseError(status, body)
+}
+
+func (t *tcpGetter) Touch(group string, key string, ttl time.Duration) (bool, error) {
+	payload := binary.BigEndian.AppendUint64(appendKey(nil, group, key), uint64(ttl))
+	status, body, err := t.call(opTouch, payload)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case statusOK:
+		return true, nil
+	case statusNotFound:
+		return false, nil
+	}
+	return false, responseError(status, body)
+}
+
+func (t *tcpGetter) Peek(group string, key string) (ByteView, bool, error) {
+	status, body, err := t.call(opPeek, appendKey(nil, group, key))
+	if err != nil {
+		return ByteView{}, false, err
+	}
+	switch status {
+	case statusOK:
+		view, err := decodeValue(body)
+		return view, err == nil, err
+	case statusNotFound:
+		return ByteView{}, false, nil
+	}
+	return ByteView{}, false, responseError(status, body)
+}
+
+var _ PeerGetter = (*tcpGetter)(nil)
+var _ PeerVersionedGetter = (*tcpGetter)(nil)
+var _ PeerSetter = (*tcpGetter)(nil)
+var _ PeerPeeker = (*tcpGetter)(nil)
+
+func (t *tcpGetter) call(op byte, payload []byte) (byte, []byte, error) {
+	c, err := t.conn()
+	if err != nil {
+		return 0, nil, err
+	}
+	return c.call(op, payload, t.opts.Timeout)
+}
+
+// conn returns the next connection in turn, dialing it when needed
+func (t *tcpGetter) conn() (*tcpConn, error) {
+	i := int(t.next.Add(1) % uint32(len(t.conns)))
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("peer %s: %w", t.addr, net.ErrClosed)
+	}
+	if c := t.conns[i]; c != nil && c.broken() == nil {
+		t.mu.Unlock()
+		return c, nil
+	}
+	t.mu.Unlock()
+
+	// dialing does not hold back the requests on the other connections
+	d := net.Dialer{Timeout: t.opts.DialTimeout, KeepAlive: t.opts.KeepAlive}
+	conn, err := d.Dial("tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		conn.Close()
+		return nil, fmt.Errorf("peer %s: %w", t.addr, net.ErrClosed)
+	}
+	if c := t.conns[i]; c != nil && c.broken() == nil {
+		conn.Close() // dialed concurrently
+		return c, nil
+	}
+	c := newTCPConn(conn, t.opts)
+	t.conns[i] = c
+	return c, nil
+}
+
+func (t *tcpGetter) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for _, c := range t.conns {
+		if c != nil {
+			c.close(net.ErrClosed)
+		}
+	}
+}
+
+type response struct {
+	status byte
+	body   []byte
+}
+
+// tcpConn is a connection to a peer shared by concurrent requests
+type tcpConn struct {
+	conn     net.Conn
+	w        *frameWriter
+	lastRead atomic.Int64 // unix nanoseconds
+	done     chan struct{}
+
+	mu      sync.Mutex // guards later three variables
+	nextID  uint64
+	pending map[uint64]chan response
+	err     error // set once the connection is broken
+}
+
+func newTCPConn(conn net.Conn, opts *TCPPoolOptions) *tcpConn {
+	c := &tcpConn{
+		conn:    conn,
+		w:       &frameWriter{conn: conn, w: bufio.NewWriter(conn)},
+		done:    make(chan struct{}),
+		pending: make(map[uint64]chan response),
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	go c.readLoop()
+	go c.keepAlive(opts.KeepAlive, opts.Timeout)
+	return c
+}
+
+func (c *tcpConn) call(op byte, payload []byte, timeout time.Duration) (byte, []byte, error) {
+	if len(payload) > maxPayloadSize {
+		return 0, nil, fmt.Errorf("peer %s: %w", c.conn.RemoteAddr(), errFrameTooLarge)
+	}
+	// the timeout covers sending the request as well
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	ch := make(chan response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return 0, nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.w.write(id, op, payload, time.Now().Add(timeout)); err != nil {
+		c.close(err)
+		return 0, nil, err
+	}
+
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return 0, nil, c.broken()
+		}
+		return res.status, res.body, nil
+	case <-timer.C:
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return 0, nil, fmt.Errorf("peer %s: request timed out after %v", c.conn.RemoteAddr(), timeout)
+	}
+}
+
+// readLoop hands the responses to the waiting requests
+func (c *tcpConn) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		id, status, body, err := readFrame(r)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		c.lastRead.Store(time.Now().UnixNano())
+
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok { // otherwise the request timed out
+			ch <- response{status: status, body: body}
+		}
+	}
+}
+
+// keepAlive pings the peer when nothing was read for idle, closing the
+// connection if the peer does not answer.
+func (c *tcpConn) keepAlive(idle time.Duration, timeout time.Duration) {
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, c.lastRead.Load())) < idle {
+			continue
+		}
+		if _, _, err := c.call(opPing, nil, timeout); err != nil {
+			c.close(err)
+			return
+		}
+	}
+}
+
+// broken returns the error which broke the connection, nil if it is usable
+func (c *tcpConn) broken() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// close fails the pending requests with err
+func (c *tcpConn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = fmt.Errorf("peer %s: %w", c.conn.RemoteAddr(), err)
+	c.conn.Close()
+	close(c.done)
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// ********************** frames *************************
+
+type frameWriter struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+// write sends a frame, failing if it is not sent by deadline unless zero
+func (f *frameWriter) write(id uint64, code byte, payload []byte, deadline time.Time) error {
+	var header [frameHeaderLen]byte
+	binary.BigEndian.PutUint32(header[0:], uint32(8+1+len(payload)))
+	binary.BigEndian.PutUint64(header[4:], id)
+	header[12] = code
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conn.SetWriteDeadline(deadline)
+	f.w.Write(header[:])
+	f.w.Write(payload)
+	return f.w.Flush()
+}
+
+func readFrame(r io.Reader) (id uint64, code byte, payload []byte, err error) {
+	var header [frameHeaderLen]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	n := binary.BigEndian.Uint32(header[0:])
+	if n < 8+1 || n > maxFrameSize {
+		err = errFrameTooLarge
+		return
+	}
+	id = binary.BigEndian.Uint64(header[4:])
+	code = header[12]
+	payload = make([]byte, n-8-1)
+	if _, err = io.ReadFull(r, payload); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func appendKey(b []byte, group string, key string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(group)))
+	b = append(b, group...)
+	b = binary.AppendUvarint(b, uint64(len(key)))
+	return append(b, key...)
+}
+
+// appendValue encodes version, expiry in unix milliseconds (0 for never),
+// flags and the bytes of view
+func appendValue(b []byte, view ByteView) []byte {
+	var expire int64
+	if !view.expire.IsZero() {
+		expire = view.expire.UnixMilli()
+	}
+	b = binary.BigEndian.AppendUint64(b, view.version)
+	b = binary.BigEndian.AppendUint64(b, uint64(expire))
+	b = binary.BigEndian.AppendUint32(b, view.flags)
+	return append(b, view.b...)
+}
+
+func decodeValue(b []byte) (ByteView, error) {
+	d := decoder{b: b}
+	view := ByteView{version: d.uint64()}
+	if ms := int64(d.uint64()); ms != 0 {
+		view.expire = time.UnixMilli(ms)
+	}
+	view.flags = d.uint32()
+	view.b = d.rest()
+	return view, d.err
+}
+
+func responseError(status byte, body []byte) error {
+	switch status {
+	case statusError:
+		return fmt.Errorf("server returned: %s", body)
+	case statusOverloaded:
+		return fmt.Errorf("%w: server returned: %s", ErrOverloaded, body)
+	}
+	return fmt.Errorf("server returned: unexpected status %d", status)
+}
+
+// decoder reads a payload, the first error sticks
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) uint8() byte {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	n, size := binary.Uvarint(d.b)
+	if size <= 0 || n > uint64(len(d.b)-size) {
+		d.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	d.b = d.b[size:]
+	return string(d.take(int(n)))
+}
+
+// rest returns the remaining bytes
+func (d *decoder) rest() []byte {
+	if d.err != nil {
+		return nil
+	}
+	b := d.b
+	d.b = nil
+	return b
+}
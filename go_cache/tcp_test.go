@@ -0,0 +1,241 @@
+package go_cache_test
+
+import (
+	"fmt"
+	"go_cache"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTCPPool serves a pool on a random local port
+func startTCPPool(t testing.TB) (*go_cache.TCPPool, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := go_cache.NewTCPPool(l.Addr().String(), nil)
+	go pool.Serve(l)
+	t.Cleanup(func() { pool.Close() })
+	return pool, l.Addr().String()
+}
+
+func TestTCPPool(t *testing.T) {
+	go_cache.NewGroup("tcp", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "missing" {
+				return nil, fmt.Errorf("%s: %w", key, go_cache.ErrNotFound)
+			}
+			return []byte("loaded"), nil
+		}))
+
+	_, addr := startTCPPool(t)
+	client := go_cache.NewTCPPool("local", nil)
+	defer client.Close()
+	var hashed int
+	client.SetHash(10, func(data []byte) uint32 {
+		hashed++
+		return uint32(len(data))
+	})
+	client.Set(addr)
+	peer, ok := client.PickPeer("k1")
+	if !ok || hashed != 11 {
+		t.Fatalf("PickPeer = %v, hashed %d times, want the remote peer by the custom hash", ok, hashed)
+	}
+
+	getter := peer.(go_cache.PeerVersionedGetter)
+	loaded, err := getter.GetVersioned("tcp", "k1")
+	if err != nil || loaded.String() != "loaded" || loaded.Version() == 0 {
+		t.Fatalf("Get(k1) = %s@%d, %v", loaded, loaded.Version(), err)
+	}
+	if _, err := peer.Get("tcp", "missing"); err == nil {
+		t.Fatal("expect error getting missing")
+	}
+	if _, err := peer.Get("no-such-group", "k1"); err == nil {
+		t.Fatal("expect error getting from unknown group")
+	}
+
+	setter := peer.(go_cache.PeerSetter)
+	req := &go_cache.SetRequest{Value: []byte("v1"), CheckVersion: true, Version: loaded.Version(), TTL: time.Minute, Flags: 7}
+	view, err := setter.Set("tcp", "k1", req)
+	if err != nil || view.Version() <= loaded.Version() || view.Flags() != 7 || view.Expire().IsZero() {
+		t.Fatalf("Set(k1) = %s@%d flags %d expire %v, %v", view, view.Version(), view.Flags(), view.Expire(), err)
+	}
+	if _, err := setter.Set("tcp", "k1", req); err != go_cache.ErrVersionMismatch {
+		t.Fatalf("stale Set(k1) err = %v, want ErrVersionMismatch", err)
+	}
+	if got, _ := getter.GetVersioned("tcp", "k1"); got.String() != "v1" || got.Version() != view.Version() {
+		t.Fatalf("Get(k1) = %s@%d, want v1@%d", got, got.Version(), view.Version())
+	}
+
+	if ok, err := setter.Touch("tcp", "k1", 0); !ok || err != nil {
+		t.Fatalf("Touch(k1) = %v, %v", ok, err)
+	}
+	if got, _ := getter.GetVersioned("tcp", "k1"); !got.Expire().IsZero() {
+		t.Fatalf("expect k1 never expire after touch, expire = %v", got.Expire())
+	}
+	if ok, err := setter.Touch("tcp", "uncached", time.Minute); ok || err != nil {
+		t.Fatalf("Touch(uncached) = %v, %v", ok, err)
+	}
+
+	// a request too large for a frame is refused before it is sent
+	huge := &go_cache.SetRequest{Value: make([]byte, 64<<20)}
+	if _, err := setter.Set("tcp", "huge", huge); err == nil || !strings.Contains(err.Error(), "frame too large") {
+		t.Fatalf("Set(huge) err = %v, want frame too large", err)
+	}
+	if got, err := peer.Get("tcp", "k1"); err != nil || string(got) != "v1" {
+		t.Fatalf("Get(k1) after Set(huge) = %s, %v", got, err)
+	}
+}
+
+func TestTCPPoolPipelining(t *testing.T) {
+	release := make(chan struct{})
+	go_cache.NewGroup("tcp-pipelining", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "slow" {
+				<-release
+			}
+			return []byte(key), nil
+		}))
+
+	_, addr := startTCPPool(t)
+	client := go_cache.NewTCPPool("local", &go_cache.TCPPoolOptions{Conns: 1})
+	defer client.Close()
+	client.Set(addr)
+	peer, _ := client.PickPeer("k")
+
+	// a slow request must not hold up the ones sent after it on the same connection
+	slow := make(chan error, 1)
+	go func() {
+		_, err := peer.Get("tcp-pipelining", "slow")
+		slow <- err
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if b, err := peer.Get("tcp-pipelining", key); err != nil || string(b) != key {
+				t.Errorf("Get(%s) = %s, %v", key, b, err)
+			}
+		}(fmt.Sprintf("k%d", i))
+	}
+	wg.Wait()
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("Get(slow): %v", err)
+	}
+}
+
+func TestTCPPoolReconnect(t *testing.T) {
+	go_cache.NewGroup("tcp-reconnect", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	server := go_cache.NewTCPPool(addr, nil)
+	go server.Serve(l)
+
+	client := go_cache.NewTCPPool("local", &go_cache.TCPPoolOptions{Conns: 1, Timeout: time.Second})
+	defer client.Close()
+	client.Set(addr)
+	peer, _ := client.PickPeer("k1")
+	if _, err := peer.Get("tcp-reconnect", "k1"); err != nil {
+		t.Fatalf("Get(k1): %v", err)
+	}
+
+	server.Close()
+	if _, err := peer.Get("tcp-reconnect", "k1"); err == nil {
+		t.Fatal("expect error while the peer is down")
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("listen again on %s: %v", addr, err)
+	}
+	server = go_cache.NewTCPPool(addr, nil)
+	go server.Serve(l)
+	defer server.Close()
+
+	// the broken connection may only be noticed by the first request
+	peer.Get("tcp-reconnect", "k1")
+	if _, err := peer.Get("tcp-reconnect", "k1"); err != nil {
+		t.Fatalf("Get(k1) after restart: %v", err)
+	}
+}
+
+func TestTCPPoolKeepAlive(t *testing.T) {
+	_, addr := startTCPPool(t)
+	client := go_cache.NewTCPPool("local", &go_cache.TCPPoolOptions{Conns: 1, KeepAlive: 10 * time.Millisecond})
+	defer client.Close()
+	client.Set(addr)
+	peer, _ := client.PickPeer("k1")
+
+	// open the connection, then stay idle across a few pings
+	peer.Get("no-such-group", "k1")
+	time.Sleep(50 * time.Millisecond)
+	if _, err := peer.Get("no-such-group", "k1"); err == nil || err.Error() != "server returned: no such group: no-such-group" {
+		t.Fatalf("expect the connection alive after pings, err = %v", err)
+	}
+}
+
+// benchmark one small value fetched from a peer on localhost
+
+func benchmarkPeerGet(b *testing.B, peer go_cache.PeerGetter, group string) {
+	if _, err := peer.Get(group, "k"); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := peer.Get(group, "k"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkHTTPPoolGet(b *testing.B) {
+	if go_cache.GetGroup("bench-peer") == nil {
+		go_cache.NewGroup("bench-peer", 0, go_cache.GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte("small value"), nil
+			}))
+	}
+
+	srv := httptest.NewServer(go_cache.NewHTTPPool("remote"))
+	defer srv.Close()
+	pool := go_cache.NewHTTPPool("local")
+	pool.Set(srv.URL)
+	peer, _ := pool.PickPeer("k")
+
+	benchmarkPeerGet(b, peer, "bench-peer")
+}
+
+func BenchmarkTCPPoolGet(b *testing.B) {
+	if go_cache.GetGroup("bench-peer") == nil {
+		go_cache.NewGroup("bench-peer", 0, go_cache.GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte("small value"), nil
+			}))
+	}
+
+	_, addr := startTCPPool(b)
+	pool := go_cache.NewTCPPool("local", nil)
+	defer pool.Close()
+	pool.Set(addr)
+	peer, _ := pool.PickPeer("k")
+
+	benchmarkPeerGet(b, peer, "bench-peer")
+}
@@ -0,0 +1,149 @@
+// Package topk estimates the most frequent keys of a stream with the
+// space-saving algorithm, in memory bounded by the number of keys tracked.
+package topk
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Item is a tracked key. Its true count is between Count-Err and Count.
+type Item struct {
+	Key   string
+	Count uint64
+	Err   uint64 // overestimation inherited from the evicted key
+}
+
+// Tracker counts the keys of a stream, keeping the capacity most frequent.
+// A key occurring more than total/capacity times is always tracked.
+// It is not safe for concurrent access.
+type Tracker struct {
+	capacity int
+	total    uint64
+	index    map[string]int // position of the keys in items
+	items    minHeap
+}
+
+func New(capacity int) *Tracker {
+	if capacity < 1 {
+		capacity = 1
+	}
+	index := make(map[string]int, capacity)
+	return &Tracker{capacity: capacity, index: index, items: minHeap{index: index}}
+}
+
+// Add counts n occurrences of key
+func (t *Tracker) Add(key string, n uint64) {
+	t.total += n
+	if i, ok := t.index[key]; ok {
+		t.items.s[i].Count += n
+		heap.Fix(&t.items, i)
+		return
+	}
+	if len(t.items.s) < t.capacity {
+		heap.Push(&t.items, Item{Key: key, Count: n})
+		return
+	}
+	// replace the least frequent key, key may have occurred as often unnoticed
+	min := t.items.s[0]
+	delete(t.index, min.Key)
+	t.items.s[0] = Item{Key: key, Count: min.Count + n, Err: min.Count}
+	t.index[key] = 0
+	heap.Fix(&t.items, 0)
+}
+
+// Get returns the item of key if it is tracked
+func (t *Tracker) Get(key string) (Item, bool) {
+	if i, ok := t.index[key]; ok {
+		return t.items.s[i], true
+	}
+	return Item{}, false
+}
+
+// Total returns the occurrences counted, including untracked keys
+func (t *Tracker) Total() uint64 {
+	return t.total
+}
+
+// Len returns the number of keys tracked
+func (t *Tracker) Len() int {
+	return len(t.items.s)
+}
+
+// Top returns up to n items by descending count, all of them if n <= 0
+func (t *Tracker) Top(n int) []Item {
+	top := make([]Item, len(t.items.s))
+	copy(top, t.items.s)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// Decay halves the counts so that recent occurrences outweigh old ones,
+// dropping the keys counted down to 0.
+func (t *Tracker) Decay() {
+	t.total /= 2
+	items := t.items.s[:0]
+	for _, it := range t.items.s {
+		it.Count /= 2
+		it.Err /= 2
+		if it.Count > 0 {
+			items = append(items, it)
+		}
+	}
+	t.items.s = items
+	clear(t.index)
+	for i, it := range items {
+		t.index[it.Key] = i
+	}
+	heap.Init(&t.items)
+}
+
+// Remove stops tracking key
+func (t *Tracker) Remove(key string) {
+	if i, ok := t.index[key]; ok {
+		heap.Remove(&t.items, i)
+	}
+}
+
+// Reset forgets all counts
+func (t *Tracker) Reset() {
+	t.total = 0
+	t.items.s = t.items.s[:0]
+	clear(t.index)
+}
+
+// minHeap orders items by count, keeping index up to date
+type minHeap struct {
+	s     []Item
+	index map[string]int
+}
+
+func (h *minHeap) Len() int           { return len(h.s) }
+func (h *minHeap) Less(i, j int) bool { return h.s[i].Count < h.s[j].Count }
+
+func (h *minHeap) Swap(i, j int) {
+	h.s[i], h.s[j] = h.s[j], h.s[i]
+	h.index[h.s[i].Key] = i
+	h.index[h.s[j].Key] = j
+}
+
+func (h *minHeap) Push(x any) {
+	it := x.(Item)
+	h.index[it.Key] = len(h.s)
+	h.s = append(h.s, it)
+}
+
+func (h *minHeap) Pop() any {
+	it := h.s[len(h.s)-1]
+	h.s = h.s[:len(h.s)-1]
+	delete(h.index, it.Key)
+	return it
+}
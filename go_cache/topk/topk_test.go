@@ -0,0 +1,76 @@
+package topk_test
+
+import (
+	"fmt"
+	"go_cache/topk"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTop(t *testing.T) {
+	tr := topk.New(3)
+	for _, key := range []string{"a", "b", "a", "c", "a", "b"} {
+		tr.Add(key, 1)
+	}
+	want := []topk.Item{{Key: "a", Count: 3}, {Key: "b", Count: 2}, {Key: "c", Count: 1}}
+	if top := tr.Top(0); !reflect.DeepEqual(top, want) {
+		t.Fatalf("Top = %v, want %v", top, want)
+	}
+
+	// d replaces the least frequent c, inheriting its count as error
+	tr.Add("d", 1)
+	if it, ok := tr.Get("d"); !ok || it.Count != 2 || it.Err != 1 {
+		t.Fatalf("Get(d) = %v, %v", it, ok)
+	}
+	if _, ok := tr.Get("c"); ok {
+		t.Fatal("c is still tracked")
+	}
+	if top := tr.Top(1); len(top) != 1 || top[0].Key != "a" {
+		t.Fatalf("Top(1) = %v", top)
+	}
+	if tr.Total() != 7 {
+		t.Fatalf("Total = %d, want 7", tr.Total())
+	}
+
+	tr.Decay()
+	want = []topk.Item{{Key: "a", Count: 1}, {Key: "b", Count: 1}, {Key: "d", Count: 1}}
+	if top := tr.Top(0); !reflect.DeepEqual(top, want) || tr.Total() != 3 {
+		t.Fatalf("after Decay, Top = %v, Total = %d", top, tr.Total())
+	}
+	tr.Decay()
+	if tr.Len() != 0 {
+		t.Fatalf("Len = %d after decaying to 0", tr.Len())
+	}
+
+	tr.Add("x", 5)
+	tr.Remove("x")
+	if tr.Len() != 0 {
+		t.Fatal("x is still tracked")
+	}
+}
+
+func TestSkewed(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tr := topk.New(20)
+	counts := make(map[string]uint64)
+	for i := 0; i < 100000; i++ {
+		// a few hot keys in a long tail of cold ones
+		key := fmt.Sprintf("cold%d", r.Intn(10000))
+		if r.Intn(2) == 0 {
+			key = fmt.Sprintf("hot%d", r.Intn(5))
+		}
+		counts[key]++
+		tr.Add(key, 1)
+	}
+
+	top := tr.Top(5)
+	for _, it := range top {
+		if it.Key[:3] != "hot" {
+			t.Fatalf("Top(5) = %v, want the hot keys", top)
+		}
+		if c := counts[it.Key]; c > it.Count || c < it.Count-it.Err {
+			t.Fatalf("%s occurred %d times, estimated %d with error %d", it.Key, c, it.Count, it.Err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecordedSpan is a span finished under a Recorder
+type RecordedSpan struct {
+	Name    string
+	Context SpanContext
+	Parent  SpanContext // zero for a root span
+	Start   time.Time
+	End     time.Time
+	Attrs   map[string]string
+	Err     error
+}
+
+// Duration returns how long the span lasted
+func (s RecordedSpan) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Recorder is a Tracer keeping finished spans in memory, meant for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	s := &recorderSpan{r: r, span: RecordedSpan{
+		Name:    name,
+		Context: NewSpanContext(parent),
+		Parent:  parent,
+		Start:   time.Now(),
+	}}
+	return ContextWithSpanContext(ctx, s.span.Context), s
+}
+
+// Spans returns the finished spans in the order they ended
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedSpan(nil), r.spans...)
+}
+
+// Reset drops the recorded spans
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+type recorderSpan struct {
+	r    *Recorder
+	mu   sync.Mutex
+	span RecordedSpan
+	done bool
+}
+
+func (s *recorderSpan) Context() SpanContext {
+	return s.span.Context
+}
+
+func (s *recorderSpan) SetAttr(key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.span.Attrs == nil {
+		s.span.Attrs = make(map[string]string)
+	}
+	s.span.Attrs[key] = value
+}
+
+func (s *recorderSpan) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Err = err
+}
+
+func (s *recorderSpan) End() {
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.span.End = time.Now()
+	span := s.span
+	s.mu.Unlock()
+
+	s.r.mu.Lock()
+	s.r.spans = append(s.r.spans, span)
+	s.r.mu.Unlock()
+}
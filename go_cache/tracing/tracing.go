@@ -0,0 +1,151 @@
+// Package tracing is a small tracing abstraction used by go_cache to time
+// the stages of a Get. Spans are passed along in contexts, and cross
+// process boundaries as W3C traceparent headers.
+//
+// Nothing is recorded until a Tracer is installed by SetTracer, but span
+// contexts received from peers are still propagated.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// TraceparentHeader is the W3C trace context header
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies a span across processes
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether sc has non-zero trace and span ids
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a traceparent header value, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+var errBadTraceparent = errors.New("malformed traceparent")
+
+// ParseTraceparent parses a traceparent header value
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(s, "-")
+	// future versions may append fields, version ff is invalid
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, errBadTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errBadTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, errBadTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, errBadTraceparent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, errBadTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, errBadTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Span times a stage. It must be ended exactly once.
+type Span interface {
+	Context() SpanContext
+	SetAttr(key string, value string)
+	SetError(err error) // nil is ignored
+	End()
+}
+
+// Tracer starts spans
+type Tracer interface {
+	// Start starts a span that is a child of the span in ctx, if any,
+	// and returns a context carrying it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+var (
+	mu     sync.RWMutex
+	tracer Tracer
+)
+
+// SetTracer installs the Tracer used by Start, nil disables tracing.
+func SetTracer(t Tracer) {
+	mu.Lock()
+	defer mu.Unlock()
+	tracer = t
+}
+
+// Start starts a span with the installed Tracer. Without one, the returned
+// span does nothing and ctx is returned unchanged.
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	mu.RLock()
+	t := tracer
+	mu.RUnlock()
+	if t == nil {
+		return ctx, noopSpan{SpanContextFromContext(ctx)}
+	}
+	return t.Start(ctx, name)
+}
+
+type spanKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc as the parent of
+// the spans started from it. Used for span contexts received from peers.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, the zero
+// value if none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanKey{}).(SpanContext)
+	return sc
+}
+
+// NewSpanContext returns the context of a new span under parent, starting
+// a new trace if parent is not valid.
+func NewSpanContext(parent SpanContext) SpanContext {
+	sc := SpanContext{TraceID: parent.TraceID, Sampled: true}
+	if parent.IsValid() {
+		sc.Sampled = parent.Sampled
+	} else {
+		for sc.TraceID == [16]byte{} {
+			rand.Read(sc.TraceID[:])
+		}
+	}
+	for sc.SpanID == [8]byte{} {
+		rand.Read(sc.SpanID[:])
+	}
+	return sc
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) Context() SpanContext { return s.sc }
+func (noopSpan) SetAttr(string, string) {}
+func (noopSpan) SetError(error)         {}
+func (noopSpan) End()                   {}
@@ -0,0 +1,87 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"go_cache/tracing"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		in      string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true}, // future version
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		sc, err := tracing.ParseTraceparent(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseTraceparent(%q) err = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if sc.Sampled != tt.sampled {
+			t.Errorf("ParseTraceparent(%q) sampled = %v", tt.in, sc.Sampled)
+		}
+		if tt.in[:2] == "00" && sc.Traceparent() != tt.in {
+			t.Errorf("Traceparent() = %q, want %q", sc.Traceparent(), tt.in)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := tracing.NewRecorder()
+	tracing.SetTracer(r)
+	defer tracing.SetTracer(nil)
+
+	ctx, root := tracing.Start(context.Background(), "root")
+	_, child := tracing.Start(ctx, "child")
+	child.SetAttr("k", "v")
+	child.SetError(errors.New("boom"))
+	child.End()
+	child.End() // ignored
+	root.End()
+
+	spans := r.Spans()
+	if len(spans) != 2 || spans[0].Name != "child" || spans[1].Name != "root" {
+		t.Fatalf("spans = %+v", spans)
+	}
+	c, p := spans[0], spans[1]
+	if p.Parent.IsValid() || !p.Context.IsValid() {
+		t.Fatalf("root parent = %v, context = %v", p.Parent, p.Context)
+	}
+	if c.Parent != p.Context || c.Context.TraceID != p.Context.TraceID || c.Context.SpanID == p.Context.SpanID {
+		t.Fatalf("child %v not under root %v", c, p.Context)
+	}
+	if c.Attrs["k"] != "v" || c.Err == nil || c.Duration() < 0 {
+		t.Fatalf("child = %+v", c)
+	}
+
+	r.Reset()
+	if len(r.Spans()) != 0 {
+		t.Fatal("expect no spans after Reset")
+	}
+}
+
+func TestNoopPropagates(t *testing.T) {
+	sc, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := tracing.ContextWithSpanContext(context.Background(), sc)
+	ctx2, span := tracing.Start(ctx, "noop")
+	defer span.End()
+	if span.Context() != sc || tracing.SpanContextFromContext(ctx2) != sc {
+		t.Fatal("expect the remote span context kept without a tracer")
+	}
+}
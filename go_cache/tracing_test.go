@@ -0,0 +1,97 @@
+package go_cache_test
+
+import (
+	"context"
+	"go_cache"
+	"go_cache/tracing"
+	"net/http/httptest"
+	"testing"
+)
+
+// remoteGroup sends gets to another group of the peer, the remote node
+// shares the groups of this process and would otherwise wait for itself
+type remoteGroup struct {
+	peer  go_cache.PeerGetter
+	group string
+}
+
+func (r remoteGroup) PickPeer(key string) (go_cache.PeerGetter, bool) {
+	return r, true
+}
+
+func (r remoteGroup) Get(_ string, key string) ([]byte, error) {
+	return r.peer.Get(r.group, key)
+}
+
+func (r remoteGroup) GetContext(ctx context.Context, _ string, key string) (go_cache.ByteView, error) {
+	return r.peer.(go_cache.PeerContextGetter).GetContext(ctx, r.group, key)
+}
+
+func TestTracing(t *testing.T) {
+	g := go_cache.NewGroup("tracing", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			t.Errorf("expect %s loaded from the peer", key)
+			return nil, go_cache.ErrNotFound
+		}))
+	go_cache.NewGroup("tracing-remote", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	srv := httptest.NewServer(go_cache.NewHTTPPool("remote"))
+	defer srv.Close()
+	pool := go_cache.NewHTTPPool("local")
+	pool.Set(srv.URL)
+	peer, _ := pool.PickPeer("k1")
+	g.RegisterPeers(remoteGroup{peer: peer, group: "tracing-remote"})
+
+	r := tracing.NewRecorder()
+	tracing.SetTracer(r)
+	defer tracing.SetTracer(nil)
+
+	if _, err := g.GetContext(context.Background(), "k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string][]tracing.RecordedSpan)
+	for _, s := range r.Spans() {
+		spans[s.Name] = append(spans[s.Name], s)
+	}
+	byName := func(name string, n int) []tracing.RecordedSpan {
+		if len(spans[name]) != n {
+			t.Fatalf("%d %s spans, want %d: %+v", len(spans[name]), name, n, r.Spans())
+		}
+		return spans[name]
+	}
+
+	// local Get -> cache, load -> peer -> remote ServeHTTP -> Get -> cache, load -> getter
+	gets, caches, loads := byName("go_cache.Get", 2), byName("go_cache.cache", 2), byName("go_cache.load", 2)
+	peerSpan, serve, getter := byName("go_cache.peer", 1)[0], byName("go_cache.ServeHTTP", 1)[0], byName("go_cache.getter", 1)[0]
+	root := gets[1] // ends last
+	if root.Parent.IsValid() {
+		t.Fatalf("root span has parent %v", root.Parent)
+	}
+	chain := []struct {
+		child, parent tracing.RecordedSpan
+	}{
+		{caches[0], root},
+		{loads[1], root},
+		{peerSpan, loads[1]},
+		{serve, peerSpan}, // propagated by traceparent
+		{gets[0], serve},
+		{caches[1], gets[0]},
+		{loads[0], gets[0]},
+		{getter, loads[0]},
+	}
+	for _, c := range chain {
+		if c.child.Parent != c.parent.Context {
+			t.Errorf("%s is not a child of %s", c.child.Name, c.parent.Name)
+		}
+		if c.child.Context.TraceID != root.Context.TraceID {
+			t.Errorf("%s not in the root trace", c.child.Name)
+		}
+	}
+	if caches[0].Attrs["hit"] != "false" || root.Attrs["key"] != "k1" || root.Attrs["group"] != "tracing" {
+		t.Errorf("unexpected attrs cache %v, root %v", caches[0].Attrs, root.Attrs)
+	}
+}
@@ -0,0 +1,233 @@
+package go_cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go_cache/ratelimit"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// KeySource provides the keys to warm a group with, hottest first.
+type KeySource interface {
+	Keys() ([]string, error)
+}
+
+type KeySourceFunc func() ([]string, error)
+
+func (f KeySourceFunc) Keys() ([]string, error) {
+	return f()
+}
+
+// FileKeys reads one key per line from a file, e.g. one written by
+// Group.WriteKeys. Empty lines and lines starting with '#' are skipped.
+func FileKeys(path string) KeySource {
+	return KeySourceFunc(func() ([]string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readKeys(f)
+	})
+}
+
+// AccessLogKeys extracts keys from an access log by the first submatch of re,
+// ordered from the most to the least frequently accessed.
+func AccessLogKeys(path string, re *regexp.Regexp) KeySource {
+	return KeySourceFunc(func() ([]string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		counts := make(map[string]int)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if m := re.FindStringSubmatch(scanner.Text()); len(m) > 1 && m[1] != "" {
+				counts[m[1]]++
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		keys := make([]string, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if counts[keys[i]] != counts[keys[j]] {
+				return counts[keys[i]] > counts[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		return keys, nil
+	})
+}
+
+// PeerKeys fetches up to limit most recently used keys of group from the
+// HTTPPool at baseURL, e.g. "http://10.0.0.2:8008". limit <= 0 means all.
+func PeerKeys(baseURL string, group string, limit int) KeySource {
+	return KeySourceFunc(func() ([]string, error) {
+		u := fmt.Sprintf("%v%v_keys/%v?limit=%d",
+			baseURL, defaultBasePath, url.PathEscape(group), limit)
+		res, err := http.Get(u)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("server returned: %v", res.Status)
+		}
+		return readKeys(res.Body)
+	})
+}
+
+func readKeys(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// Keys returns up to limit keys cached by this node from the most recently
+// used. limit <= 0 means all.
+func (g *Group) Keys(limit int) []string {
+	return g.mainCache.keys(limit)
+}
+
+// WriteKeys writes a snapshot of the keys cached by this node, one per line,
+// to be read back by FileKeys. Keys containing a line break are skipped.
+func (g *Group) WriteKeys(w io.Writer) error {
+	return writeKeys(w, g.Keys(0))
+}
+
+func writeKeys(w io.Writer, keys []string) error {
+	bw := bufio.NewWriter(w)
+	for _, key := range keys {
+		if strings.ContainsAny(key, "\r\n") {
+			continue
+		}
+		if _, err := bw.WriteString(key + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WarmOptions controls Group.Warm
+type WarmOptions struct {
+	Concurrency int                      // parallel loads, default 4
+	Rate        float64                  // loads per second, 0 means unlimited
+	Progress    func(progress WarmStats) // optional, called after each key
+}
+
+// WarmStats reports the progress of Group.Warm
+type WarmStats struct {
+	Total   int // keys provided by the source
+	Done    int // keys processed
+	Loaded  int // keys loaded through the Getter
+	Skipped int // keys owned by other peers or already cached
+	Failed  int // keys the Getter failed to load
+}
+
+// Warm preloads the keys of src owned by this node through the Getter.
+// It stops early when ctx is done.
+func (g *Group) Warm(ctx context.Context, src KeySource, opts WarmOptions) (WarmStats, error) {
+	keys, err := src.Keys()
+	if err != nil {
+		return WarmStats{}, fmt.Errorf("reading keys: %w", err)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	limiter := ratelimit.New(opts.Rate, opts.Concurrency)
+
+	var mu sync.Mutex // guards stats
+	stats := WarmStats{Total: len(keys)}
+	report := func(update func(*WarmStats)) {
+		mu.Lock()
+		defer mu.Unlock()
+		update(&stats)
+		stats.Done++
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				if _, err := g.load(ctx, key); err != nil {
+					slog.Info("warm up failed", "group", g.name, "key", key, "err", err)
+					report(func(s *WarmStats) { s.Failed++ })
+				} else {
+					report(func(s *WarmStats) { s.Loaded++ })
+				}
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		if !g.owns(key) || g.mainCache.contains(key) {
+			report(func(s *WarmStats) { s.Skipped++ })
+			continue
+		}
+		if err = limiter.Wait(ctx); err != nil {
+			break
+		}
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	slog.Info("warm up finished", "group", g.name, "total", stats.Total,
+		"loaded", stats.Loaded, "skipped", stats.Skipped, "failed", stats.Failed)
+	return stats, err
+}
+
+// owns reports whether this node is the owner of key
+func (g *Group) owns(key string) bool {
+	if key == "" {
+		return false
+	}
+	if g.peers != nil {
+		if _, ok := g.peers.PickPeer(key); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// serveKeys lists the most recently used keys of a group, one per line
+func (p *HTTPPool) serveKeys(w http.ResponseWriter, r *http.Request, groupName string) {
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeKeys(w, group.Keys(limit))
+}
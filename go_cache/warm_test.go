@@ -0,0 +1,81 @@
+package go_cache_test
+
+import (
+	"context"
+	"fmt"
+	"go_cache"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWarm(t *testing.T) {
+	var loads atomic.Int32
+	g := go_cache.NewGroup("warm", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			loads.Add(1)
+			if key == "bad" {
+				return nil, fmt.Errorf("%s not exist", key)
+			}
+			return []byte(key), nil
+		}))
+	g.Get("k1") // already cached
+
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# hot keys\nk1\nk2\n\nk3\nbad\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var last go_cache.WarmStats
+	stats, err := g.Warm(context.Background(), go_cache.FileKeys(path), go_cache.WarmOptions{
+		Concurrency: 2,
+		Rate:        1000,
+		Progress:    func(s go_cache.WarmStats) { last = s },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := go_cache.WarmStats{Total: 4, Done: 4, Loaded: 2, Skipped: 1, Failed: 1}
+	if stats != want || last != want {
+		t.Fatalf("stats = %+v, last progress = %+v, want %+v", stats, last, want)
+	}
+	if got := loads.Load(); got != 4 {
+		t.Fatalf("loads = %d, want 4", got)
+	}
+}
+
+func TestPeerKeys(t *testing.T) {
+	g := go_cache.NewGroup("warm-peer", 0, go_cache.GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	g.Get("k1")
+	g.Get("k2")
+	g.Get("k3")
+
+	srv := httptest.NewServer(go_cache.NewHTTPPool("remote"))
+	defer srv.Close()
+
+	keys, err := go_cache.PeerKeys(srv.URL, "warm-peer", 2).Keys()
+	if want := []string{"k3", "k2"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("PeerKeys = %v, %v, want %v", keys, err, want)
+	}
+}
+
+func TestAccessLogKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	log := "GET /api?key=Tom 200\nGET /api?key=Sam 200\nGET /health 200\nGET /api?key=Sam 200\n"
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := go_cache.AccessLogKeys(path, regexp.MustCompile(`key=(\w+)`)).Keys()
+	if want := []string{"Sam", "Tom"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("AccessLogKeys = %v, %v, want %v", keys, err, want)
+	}
+}
@@ -61,6 +61,40 @@ func (g *RouterGroup) GET(pattern string, handler HandlerFunc) {
 func (g *RouterGroup) POST(pattern string, handler HandlerFunc) {
 	g.addRoute("POST", pattern, handler)
 }
+func (g *RouterGroup) PUT(pattern string, handler HandlerFunc) {
+	g.addRoute("PUT", pattern, handler)
+}
+func (g *RouterGroup) PATCH(pattern string, handler HandlerFunc) {
+	g.addRoute("PATCH", pattern, handler)
+}
+func (g *RouterGroup) DELETE(pattern string, handler HandlerFunc) {
+	g.addRoute("DELETE", pattern, handler)
+}
+
+// HEAD requests without a HEAD route are served by the GET route, without the body
+func (g *RouterGroup) HEAD(pattern string, handler HandlerFunc) {
+	g.addRoute("HEAD", pattern, handler)
+}
+
+// OPTIONS requests without an OPTIONS route are answered with the Allow header
+func (g *RouterGroup) OPTIONS(pattern string, handler HandlerFunc) {
+	g.addRoute("OPTIONS", pattern, handler)
+}
+
+// register route of any method, e.g. "PROPFIND"
+func (g *RouterGroup) Handle(method string, pattern string, handler HandlerFunc) {
+	g.addRoute(strings.ToUpper(method), pattern, handler)
+}
+
+// anyMethods are registered by Any
+var anyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// register route of all the common methods
+func (g *RouterGroup) Any(pattern string, handler HandlerFunc) {
+	for _, method := range anyMethods {
+		g.addRoute(method, pattern, handler)
+	}
+}
 
 // func (e *Engine) addRoute(method string, pattern string, handler HandlerFunc) {
 // 	e.router.addRoute(method, pattern, handler)
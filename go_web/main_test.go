@@ -1,6 +1,10 @@
 package go_web_test
 
 import (
+	"go_web"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -14,3 +18,50 @@ func Test_tmp(t *testing.T) {
 	ss = append(ss, "xx")
 	t.Log(ss)
 }
+
+func TestMethods(t *testing.T) {
+	r := go_web.New()
+	api := r.Group("/api")
+	api.GET("/items/:id", func(c *go_web.Context) {
+		c.SetHeader("X-Id", c.Param("id"))
+		c.String(http.StatusOK, "item %s", c.Param("id"))
+	})
+	api.PUT("/items/:id", func(c *go_web.Context) { c.String(http.StatusOK, "put") })
+	api.PATCH("/items/:id", func(c *go_web.Context) { c.String(http.StatusOK, "patch") })
+	api.DELETE("/items/:id", func(c *go_web.Context) { c.Status(http.StatusNoContent) })
+	api.Handle("propfind", "/items/:id", func(c *go_web.Context) { c.String(http.StatusOK, "propfind") })
+	api.Any("/any", func(c *go_web.Context) { c.String(http.StatusOK, "any %s", c.Method) })
+	api.POST("/form", func(c *go_web.Context) { c.String(http.StatusOK, "post") })
+	api.OPTIONS("/form", func(c *go_web.Context) { c.String(http.StatusOK, "custom options") })
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		header string // "Key: value" expected in the response
+	}{
+		{"GET", "/api/items/1", http.StatusOK, "item 1", "X-Id: 1"},
+		{"PUT", "/api/items/1", http.StatusOK, "put", ""},
+		{"PATCH", "/api/items/1", http.StatusOK, "patch", ""},
+		{"DELETE", "/api/items/1", http.StatusNoContent, "", ""},
+		{"PROPFIND", "/api/items/1", http.StatusOK, "propfind", ""},
+		{"HEAD", "/api/items/2", http.StatusOK, "", "X-Id: 2"},
+		{"OPTIONS", "/api/items/1", http.StatusNoContent, "", "Allow: DELETE, GET, HEAD, OPTIONS, PATCH, PROPFIND, PUT"},
+		{"OPTIONS", "/api/form", http.StatusOK, "custom options", ""},
+		{"OPTIONS", "/api/none", http.StatusNotFound, "404 not found: /api/none", ""},
+		{"HEAD", "/api/form", http.StatusNotFound, "404 not found: /api/form", ""},
+		{"DELETE", "/api/any", http.StatusOK, "any DELETE", ""},
+		{"HEAD", "/api/any", http.StatusOK, "any HEAD", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || w.Body.String() != tt.body {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body, tt.status, tt.body)
+		}
+		if key, value, ok := strings.Cut(tt.header, ": "); ok && w.Header().Get(key) != value {
+			t.Errorf("%s %s header %s = %q, want %q", tt.method, tt.path, key, w.Header().Get(key), value)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package go_web
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 )
 
@@ -68,17 +69,58 @@ func (r *router) getRoute(
 	return nil, nil
 }
 
+// getAllowed returns the methods having a route matching path, sorted
+func (r *router) getAllowed(path string) []string {
+	allowed := make([]string, 0)
+	for method := range r.roots {
+		if node, _ := r.getRoute(method, path); node != nil {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	if slices.Contains(allowed, "GET") && !slices.Contains(allowed, "HEAD") {
+		allowed = append(allowed, "HEAD")
+	}
+	if !slices.Contains(allowed, "OPTIONS") {
+		allowed = append(allowed, "OPTIONS")
+	}
+	slices.Sort(allowed)
+	return allowed
+}
+
 func (r *router) handle(c *Context) {
-	node, params := r.getRoute(c.Method, c.Path)
+	method := c.Method
+	node, params := r.getRoute(method, c.Path)
+
+	// HEAD falls back to GET, writing the headers only
+	if node == nil && method == "HEAD" {
+		if node, params = r.getRoute("GET", c.Path); node != nil {
+			method = "GET"
+			c.Writer = headWriter{c.Writer}
+		}
+	}
+
+	// OPTIONS is answered by the methods of the path
+	var allowed []string
+	if node == nil && method == "OPTIONS" {
+		allowed = r.getAllowed(c.Path)
+	}
 
 	if node != nil {
-		key := c.Method + "-" + node.pattern
+		key := method + "-" + node.pattern
 		if handler, ok := r.handlers[key]; ok {
 			c.Params = params
 			c.handlers = append(c.handlers, handler)
 		} else {
 			panic(fmt.Sprintf("there is `%s` in trie three but don't have handleFunc", key))
 		}
+	} else if allowed != nil {
+		c.handlers = append(c.handlers, func(c *Context) {
+			c.SetHeader("Allow", strings.Join(allowed, ", "))
+			c.Status(http.StatusNoContent)
+		})
 	} else {
 		c.handlers = append(c.handlers, func(c *Context) {
 			c.String(http.StatusNotFound, "404 not found: %s", c.Path)
@@ -87,6 +129,15 @@ func (r *router) handle(c *Context) {
 	c.Next()
 }
 
+// headWriter drops the body of responses to HEAD requests
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
 func parseParts(pattern string) []string {
 	slice := strings.Split(pattern, "/")
 